@@ -1,8 +1,14 @@
-module jam-cli
+module github.com/jam-launch/jam-cli
 
 go 1.23.4
 
-require github.com/jedib0t/go-pretty v4.3.0+incompatible
+require (
+	github.com/chzyer/readline v1.5.1
+	github.com/jedib0t/go-pretty v4.3.0+incompatible
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	golang.org/x/term v0.27.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/asaskevich/govalidator v0.0.0-20230301143203-a9d515a09cc2 // indirect
@@ -14,5 +20,5 @@ require (
 	github.com/oklog/ulid v1.3.1 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
 	go.mongodb.org/mongo-driver v1.14.0 // indirect
-	golang.org/x/sys v0.17.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
 )
@@ -0,0 +1,257 @@
+// Package jamlaunch is a client for the JamLaunch REST API.
+package jamlaunch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client sends authenticated requests to a JamLaunch API deployment.
+type Client struct {
+	// BaseURL is the API root, e.g. "https://api.jamlaunch.com".
+	BaseURL string
+	// Token is sent as a bearer token with every request.
+	Token string
+	// HTTPClient is used to send requests. http.DefaultClient is used when nil.
+	HTTPClient *http.Client
+	// Timeout bounds each attempt of a request. Zero means no timeout beyond
+	// the caller's context.
+	Timeout time.Duration
+	// Retry controls retries of failed requests.
+	Retry RetryPolicy
+	// Logf, when set, receives diagnostics about retries and rate limits.
+	Logf func(format string, args ...interface{})
+
+	rateLimit *RateLimit
+}
+
+// DefaultTimeout is the per-attempt timeout of clients created with NewClient.
+const DefaultTimeout = 30 * time.Second
+
+// NewClient returns a client for the API at baseURL authenticated with token.
+func NewClient(baseURL string, token string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		Timeout: DefaultTimeout,
+		Retry:   DefaultRetryPolicy,
+	}
+}
+
+// URL joins path onto the client's base URL.
+func (c *Client) URL(path string) string {
+	return c.BaseURL + "/" + strings.TrimPrefix(path, "/")
+}
+
+// RateLimit returns the quota reported by the most recent response, if the
+// server sent rate limit headers.
+func (c *Client) RateLimit() (RateLimit, bool) {
+	if c.rateLimit == nil {
+		return RateLimit{}, false
+	}
+	return *c.rateLimit, true
+}
+
+// Get requests path and decodes the JSON response into out.
+func (c *Client) Get(ctx context.Context, path string, out interface{}) error {
+	return c.do(ctx, http.MethodGet, path, nil, out)
+}
+
+// Post sends body as JSON to path and decodes the JSON response into out.
+func (c *Client) Post(ctx context.Context, path string, body interface{}, out interface{}) error {
+	return c.do(ctx, http.MethodPost, path, body, out)
+}
+
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+	var jsonData []byte
+	if body != nil {
+		var err error
+		jsonData, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+	}
+
+	started := time.Now()
+	for attempt := 1; ; attempt++ {
+		resp, respBody, err := c.send(ctx, method, path, jsonData)
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s %s: %w", method, path, ctx.Err())
+		}
+
+		delay, retry := c.Retry.nextDelay(attempt, started, method, resp, err)
+		if !retry {
+			if err != nil {
+				return fmt.Errorf("request failed: %w", err)
+			}
+			return c.decode(method, path, resp, respBody, out)
+		}
+
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+		}
+		c.logf("%s %s: %s; retrying in %s (attempt %d of %d)", method, path, reason, delay.Round(time.Millisecond), attempt+1, c.Retry.MaxAttempts)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%s %s: %w", method, path, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// send performs a single attempt and returns the response with its body
+// already read and closed.
+func (c *Client) send(ctx context.Context, method string, path string, jsonData []byte) (*http.Response, []byte, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	var reqBody io.Reader
+	if jsonData != nil {
+		reqBody = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.URL(path), reqBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/json")
+	if jsonData != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	if limit, ok := parseRateLimit(resp.Header); ok {
+		c.rateLimit = &limit
+		if limit.Limit > 0 {
+			c.logf("%s %s: %d, rate limit %d of %d remaining", method, path, resp.StatusCode, limit.Remaining, limit.Limit)
+		} else {
+			c.logf("%s %s: %d, rate limit %d remaining", method, path, resp.StatusCode, limit.Remaining)
+		}
+	}
+
+	return resp, respBody, nil
+}
+
+func (c *Client) decode(method string, path string, resp *http.Response, respBody []byte, out interface{}) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(method, path, resp, respBody)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		var fieldErr *FieldError
+		if errors.As(err, &fieldErr) {
+			return fmt.Errorf("error decoding %s %s response: %w", method, path, err)
+		}
+		return fmt.Errorf("error decoding %s %s response (%s): %w", method, path, snippet(respBody), err)
+	}
+
+	return nil
+}
+
+// ListProjects returns the projects the authenticated user can access.
+func (c *Client) ListProjects(ctx context.Context) ([]ProjectSummary, error) {
+	var resp struct {
+		Projects *[]ProjectSummary `json:"projects"`
+	}
+	if err := c.Get(ctx, "projects", &resp); err != nil {
+		return nil, err
+	}
+	if resp.Projects == nil {
+		return nil, &FieldError{Type: "project list", Field: "projects", Reason: "is missing"}
+	}
+	return *resp.Projects, nil
+}
+
+// GetProject returns the details, members and releases of a project.
+func (c *Client) GetProject(ctx context.Context, projectID string) (*Project, error) {
+	var project Project
+	if err := c.Get(ctx, "projects/"+url.PathEscape(projectID), &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// ListSessions returns the game sessions of a project.
+func (c *Client) ListSessions(ctx context.Context, projectID string) ([]SessionSummary, error) {
+	var resp struct {
+		Sessions []SessionSummary `json:"sessions"`
+	}
+	if err := c.Get(ctx, "projects/"+url.PathEscape(projectID)+"/sessions", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Sessions, nil
+}
+
+// GetSession returns a single game session and its players.
+func (c *Client) GetSession(ctx context.Context, projectID string, sessionID string) (*Session, error) {
+	var session Session
+	path := "projects/" + url.PathEscape(projectID) + "/sessions/" + url.PathEscape(sessionID)
+	if err := c.Get(ctx, path, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RevokeToken asks the server to invalidate the client's token. Servers
+// without revocation answer 404, 405 or 501.
+func (c *Client) RevokeToken(ctx context.Context) error {
+	return c.Post(ctx, "device-auth/revoke", map[string]string{"token": c.Token}, nil)
+}
+
+// CreateTestKey issues a test player token for a release of a project.
+func (c *Client) CreateTestKey(ctx context.Context, projectID string, releaseID string, testNum int) (*TestKey, error) {
+	body := map[string]interface{}{
+		"release":  releaseID,
+		"test_num": testNum,
+	}
+
+	var key TestKey
+	if err := c.Post(ctx, "projects/"+url.PathEscape(projectID)+"/testkey", body, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
@@ -0,0 +1,151 @@
+package jamlaunch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// FieldError reports a response field that is missing or has the wrong type.
+type FieldError struct {
+	Type   string
+	Field  string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: field %q %s", e.Type, e.Field, e.Reason)
+}
+
+// object decodes the fields of a JSON object one at a time, keeping the first
+// error so model UnmarshalJSON methods can stay linear.
+type object struct {
+	kind   string
+	fields map[string]json.RawMessage
+	err    error
+}
+
+func decodeObject(kind string, data []byte) (*object, error) {
+	o := &object{kind: kind}
+	if err := json.Unmarshal(data, &o.fields); err != nil {
+		return nil, fmt.Errorf("%s: expected a JSON object: %w", kind, err)
+	}
+	return o, nil
+}
+
+func (o *object) field(key string, required bool) (json.RawMessage, bool) {
+	if o.err != nil {
+		return nil, false
+	}
+	raw, ok := o.fields[key]
+	if !ok || string(raw) == "null" {
+		if required {
+			o.err = &FieldError{Type: o.kind, Field: key, Reason: "is missing"}
+		}
+		return nil, false
+	}
+	return raw, true
+}
+
+func (o *object) decode(key string, required bool, dst interface{}, want string) {
+	raw, ok := o.field(key, required)
+	if !ok {
+		return
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		o.err = &FieldError{Type: o.kind, Field: key, Reason: "is not " + want}
+	}
+}
+
+func (o *object) string(key string, required bool, dst *string) {
+	o.decode(key, required, dst, "a string")
+}
+
+func (o *object) bool(key string, required bool, dst *bool) {
+	o.decode(key, required, dst, "a boolean")
+}
+
+// text accepts a string, number or boolean and stores its textual form. It is
+// used for fields whose JSON type the API has not pinned down.
+func (o *object) text(key string, required bool, dst *string) {
+	raw, ok := o.field(key, required)
+	if !ok {
+		return
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		o.err = &FieldError{Type: o.kind, Field: key, Reason: "is not valid JSON"}
+		return
+	}
+
+	switch value := v.(type) {
+	case string:
+		*dst = value
+	case float64:
+		*dst = strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		*dst = strconv.FormatBool(value)
+	default:
+		o.err = &FieldError{Type: o.kind, Field: key, Reason: "is not a scalar value"}
+	}
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// time accepts RFC 3339 style timestamps or Unix epoch numbers, in seconds or
+// milliseconds.
+func (o *object) time(key string, required bool, dst *time.Time) {
+	raw, ok := o.field(key, required)
+	if !ok {
+		return
+	}
+
+	var epoch float64
+	if err := json.Unmarshal(raw, &epoch); err == nil {
+		if epoch > 1e12 {
+			*dst = time.UnixMilli(int64(epoch)).UTC()
+		} else {
+			*dst = time.Unix(int64(epoch), 0).UTC()
+		}
+		return
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		o.err = &FieldError{Type: o.kind, Field: key, Reason: "is not a timestamp"}
+		return
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			*dst = t
+			return
+		}
+	}
+
+	o.err = &FieldError{Type: o.kind, Field: key, Reason: fmt.Sprintf("has unrecognised timestamp %q", s)}
+}
+
+func (o *object) list(key string, dst interface{}) {
+	raw, ok := o.field(key, false)
+	if !ok {
+		return
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		var fieldErr *FieldError
+		if errors.As(err, &fieldErr) {
+			o.err = err
+			return
+		}
+		o.err = &FieldError{Type: o.kind, Field: key, Reason: "is not a list"}
+	}
+}
@@ -0,0 +1,118 @@
+package jamlaunch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors matched by APIError through errors.Is. ErrUnauthorized
+// means the token itself was refused (401); ErrForbidden means it was
+// accepted but does not grant access to the resource (403).
+var (
+	ErrUnauthorized = errors.New("not authorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrServer       = errors.New("server error")
+)
+
+// APIError is returned for any response with a non-2xx status code.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// RequestID identifies the request in the server logs, when provided.
+	RequestID string
+	// Code and Message come from the JSON error body, when there is one.
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		b.WriteString(": " + e.Message)
+	}
+	if e.Code != "" && e.Code != e.Message {
+		b.WriteString(" (" + e.Code + ")")
+	}
+	if e.RequestID != "" {
+		b.WriteString(" [request id " + e.RequestID + "]")
+	}
+	return b.String()
+}
+
+// Is lets callers classify failures with errors.Is(err, ErrUnauthorized),
+// ErrForbidden, ErrNotFound or ErrServer.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+var requestIDHeaders = []string{"X-Request-Id", "X-Amzn-Requestid", "Apigw-Requestid", "X-Correlation-Id"}
+
+func newAPIError(method string, path string, resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		Method:     method,
+		Path:       path,
+		StatusCode: resp.StatusCode,
+	}
+
+	for _, header := range requestIDHeaders {
+		if id := resp.Header.Get(header); id != "" {
+			apiErr.RequestID = id
+			break
+		}
+	}
+
+	var errBody struct {
+		Message   string `json:"message"`
+		Error     string `json:"error"`
+		Detail    string `json:"detail"`
+		Code      string `json:"code"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(body, &errBody); err == nil {
+		apiErr.Message = firstNonEmpty(errBody.Message, errBody.Detail, errBody.Error)
+		apiErr.Code = firstNonEmpty(errBody.Code, errBody.Error)
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = errBody.RequestID
+		}
+	} else {
+		apiErr.Message = snippet(body)
+	}
+
+	return apiErr
+}
+
+// snippet shortens a non-JSON body so it can be shown in an error message.
+func snippet(body []byte) string {
+	const maxLen = 200
+
+	s := strings.Join(strings.Fields(string(body)), " ")
+	if len(s) > maxLen {
+		s = s[:maxLen] + "..."
+	}
+	return s
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
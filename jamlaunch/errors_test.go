@@ -0,0 +1,54 @@
+package jamlaunch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	sentinels := []error{ErrUnauthorized, ErrForbidden, ErrNotFound, ErrServer}
+
+	tests := []struct {
+		status int
+		want   error // nil when no sentinel matches
+	}{
+		{http.StatusBadRequest, nil},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusTooManyRequests, nil},
+		{http.StatusInternalServerError, ErrServer},
+		{http.StatusBadGateway, ErrServer},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			err := fmt.Errorf("wrapped: %w", &APIError{Method: http.MethodGet, Path: "projects", StatusCode: tt.status})
+			for _, sentinel := range sentinels {
+				if got := errors.Is(err, sentinel); got != (sentinel == tt.want) {
+					t.Errorf("errors.Is(%d, %v) = %v", tt.status, sentinel, got)
+				}
+			}
+		})
+	}
+}
+
+func TestClientReturnsAPIError(t *testing.T) {
+	client, _ := stubServer(t, http.Header{"X-Request-Id": {"req-1"}}, http.StatusForbidden)
+
+	err := client.Get(context.Background(), "projects", nil)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusForbidden || apiErr.RequestID != "req-1" {
+		t.Errorf("APIError = %+v, want 403 with request id req-1", apiErr)
+	}
+	if !errors.Is(err, ErrForbidden) || errors.Is(err, ErrUnauthorized) {
+		t.Errorf("403 classified as unauthorized: %v", err)
+	}
+}
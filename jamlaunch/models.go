@@ -0,0 +1,159 @@
+package jamlaunch
+
+import "time"
+
+// ProjectSummary is an entry of the project list.
+type ProjectSummary struct {
+	ID   string `json:"id"`
+	Name string `json:"project_name"`
+}
+
+func (p *ProjectSummary) UnmarshalJSON(data []byte) error {
+	o, err := decodeObject("project", data)
+	if err != nil {
+		return err
+	}
+	o.string("id", true, &p.ID)
+	o.string("project_name", true, &p.Name)
+	return o.err
+}
+
+// Project is a single project with its members and releases.
+type Project struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"project_name"`
+	CreatedAt time.Time `json:"created_at"`
+	Active    bool      `json:"active"`
+	Members   []Member  `json:"members"`
+	Releases  []Release `json:"releases"`
+}
+
+func (p *Project) UnmarshalJSON(data []byte) error {
+	o, err := decodeObject("project", data)
+	if err != nil {
+		return err
+	}
+	o.string("id", true, &p.ID)
+	o.string("project_name", true, &p.Name)
+	o.time("created_at", true, &p.CreatedAt)
+	o.bool("active", true, &p.Active)
+	o.list("members", &p.Members)
+	o.list("releases", &p.Releases)
+	return o.err
+}
+
+// Member is a user with access to a project.
+type Member struct {
+	Username string `json:"username"`
+	Level    string `json:"level"`
+}
+
+func (m *Member) UnmarshalJSON(data []byte) error {
+	o, err := decodeObject("member", data)
+	if err != nil {
+		return err
+	}
+	o.string("username", true, &m.Username)
+	o.text("level", false, &m.Level)
+	return o.err
+}
+
+// Release is an uploaded build of a project.
+type Release struct {
+	ID          string    `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	IsDefault   bool      `json:"is_default"`
+	Public      bool      `json:"public"`
+	NetworkMode string    `json:"network_mode"`
+	ServerBuild bool      `json:"server_build"`
+	AllowGuests bool      `json:"allow_guests"`
+}
+
+func (r *Release) UnmarshalJSON(data []byte) error {
+	o, err := decodeObject("release", data)
+	if err != nil {
+		return err
+	}
+	o.string("id", true, &r.ID)
+	o.time("created_at", false, &r.CreatedAt)
+	o.bool("is_default", false, &r.IsDefault)
+	o.bool("public", false, &r.Public)
+	o.text("network_mode", false, &r.NetworkMode)
+	o.bool("server_build", false, &r.ServerBuild)
+	o.bool("allow_guests", false, &r.AllowGuests)
+	return o.err
+}
+
+// SessionSummary is an entry of a project's session list.
+type SessionSummary struct {
+	ID        string    `json:"id"`
+	Address   string    `json:"address"`
+	CreatedAt time.Time `json:"createdAt"`
+	State     string    `json:"state"`
+}
+
+func (s *SessionSummary) UnmarshalJSON(data []byte) error {
+	o, err := decodeObject("session", data)
+	if err != nil {
+		return err
+	}
+	o.string("id", true, &s.ID)
+	o.string("address", false, &s.Address)
+	o.time("createdAt", false, &s.CreatedAt)
+	o.string("state", true, &s.State)
+	return o.err
+}
+
+// Session is a running game session and its players.
+type Session struct {
+	ID       string   `json:"id"`
+	Address  string   `json:"address"`
+	JoinCode string   `json:"joinCode"`
+	Region   string   `json:"region"`
+	State    string   `json:"state"`
+	Players  []Player `json:"players"`
+}
+
+func (s *Session) UnmarshalJSON(data []byte) error {
+	o, err := decodeObject("session", data)
+	if err != nil {
+		return err
+	}
+	o.string("id", true, &s.ID)
+	o.string("address", true, &s.Address)
+	o.string("joinCode", true, &s.JoinCode)
+	o.string("region", true, &s.Region)
+	o.string("state", true, &s.State)
+	o.list("players", &s.Players)
+	return o.err
+}
+
+// Player is a participant of a game session.
+type Player struct {
+	Username string `json:"username"`
+	Host     bool   `json:"host"`
+}
+
+func (p *Player) UnmarshalJSON(data []byte) error {
+	o, err := decodeObject("player", data)
+	if err != nil {
+		return err
+	}
+	o.string("username", true, &p.Username)
+	o.bool("host", false, &p.Host)
+	return o.err
+}
+
+// TestKey is a short-lived player token for testing a release.
+type TestKey struct {
+	JWT string `json:"test_jwt"`
+}
+
+func (k *TestKey) UnmarshalJSON(data []byte) error {
+	o, err := decodeObject("test key", data)
+	if err != nil {
+		return err
+	}
+	o.string("test_jwt", true, &k.JWT)
+	return o.err
+}
@@ -0,0 +1,149 @@
+package jamlaunch
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried. Idempotent requests
+// are retried after network errors and 429, 502, 503 and 504 responses;
+// other requests only after a 429, since the server did not process them.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. It doubles on every
+	// further retry, with random jitter, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxElapsed caps the total time spent on a request including retries.
+	// A Retry-After that would exceed it ends the retries early.
+	MaxElapsed time.Duration
+}
+
+// DefaultRetryPolicy is used by clients created with NewClient.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    8 * time.Second,
+	MaxElapsed:  30 * time.Second,
+}
+
+// RateLimit is the request quota reported by the last response.
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isRetryableStatus(method string, status int) bool {
+	if status == http.StatusTooManyRequests {
+		return true
+	}
+	if !isIdempotent(method) {
+		return false
+	}
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before retry number n (starting at 1): an
+// exponentially growing delay of which a random half is jitter.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < n && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// nextDelay decides whether attempt number attempt, which failed with err or
+// returned resp, should be retried and after how long.
+func (p RetryPolicy) nextDelay(attempt int, started time.Time, method string, resp *http.Response, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	var delay time.Duration
+	switch {
+	case err != nil:
+		if !isIdempotent(method) {
+			return 0, false
+		}
+		delay = p.backoff(attempt)
+	case isRetryableStatus(method, resp.StatusCode):
+		delay = p.backoff(attempt)
+		if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			delay = after
+		}
+	default:
+		return 0, false
+	}
+
+	if p.MaxElapsed > 0 && time.Since(started)+delay > p.MaxElapsed {
+		return 0, false
+	}
+
+	return delay, true
+}
+
+// retryAfter parses a Retry-After header given as seconds or an HTTP date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		delay := time.Until(at)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+func parseRateLimit(header http.Header) (RateLimit, bool) {
+	remaining := firstNonEmpty(header.Get("X-RateLimit-Remaining"), header.Get("RateLimit-Remaining"))
+	if remaining == "" {
+		return RateLimit{}, false
+	}
+
+	var limit RateLimit
+	limit.Remaining, _ = strconv.Atoi(remaining)
+	limit.Limit, _ = strconv.Atoi(firstNonEmpty(header.Get("X-RateLimit-Limit"), header.Get("RateLimit-Limit")))
+
+	if reset, err := strconv.ParseInt(firstNonEmpty(header.Get("X-RateLimit-Reset"), header.Get("RateLimit-Reset")), 10, 64); err == nil {
+		// Small values are seconds until the reset, large ones a Unix time.
+		if reset < 1e9 {
+			limit.Reset = time.Now().Add(time.Duration(reset) * time.Second)
+		} else {
+			limit.Reset = time.Unix(reset, 0)
+		}
+	}
+
+	return limit, true
+}
@@ -0,0 +1,263 @@
+package jamlaunch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetry keeps the delays short enough for tests while still exercising
+// the backoff.
+var fastRetry = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    4 * time.Millisecond,
+	MaxElapsed:  5 * time.Second,
+}
+
+// stubServer answers each request with the next status from statuses,
+// repeating the last one, and counts the requests it received.
+func stubServer(t *testing.T, headers http.Header, statuses ...int) (*Client, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		status := statuses[min(n, len(statuses))-1]
+		for name, values := range headers {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.URL, "token")
+	client.HTTPClient = server.Client()
+	client.Retry = fastRetry
+	return client, &calls
+}
+
+func TestRetriesIdempotentRequests(t *testing.T) {
+	client, calls := stubServer(t, nil, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK)
+
+	var out struct {
+		OK bool `json:"ok"`
+	}
+	if err := client.Get(context.Background(), "thing", &out); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !out.OK {
+		t.Errorf("response was not decoded")
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+}
+
+func TestGivesUpAfterMaxAttempts(t *testing.T) {
+	client, calls := stubServer(t, nil, http.StatusServiceUnavailable)
+
+	err := client.Get(context.Background(), "thing", nil)
+	if !errors.Is(err, ErrServer) {
+		t.Fatalf("err = %v, want ErrServer", err)
+	}
+	if got := calls.Load(); got != int32(fastRetry.MaxAttempts) {
+		t.Errorf("attempts = %d, want %d", got, fastRetry.MaxAttempts)
+	}
+}
+
+func TestPostRetriedOnlyOn429(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		wantErr  bool
+		attempts int32
+	}{
+		{"server error", []int{http.StatusServiceUnavailable, http.StatusOK}, true, 1},
+		{"too many requests", []int{http.StatusTooManyRequests, http.StatusOK}, false, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, calls := stubServer(t, nil, tt.statuses...)
+
+			err := client.Post(context.Background(), "thing", map[string]string{"a": "b"}, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryAfterBeyondMaxElapsedStops(t *testing.T) {
+	client, calls := stubServer(t, http.Header{"Retry-After": {"60"}}, http.StatusTooManyRequests)
+
+	start := time.Now()
+	err := client.Get(context.Background(), "thing", nil)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("err = %v, want a 429 APIError", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %s, want no wait", elapsed)
+	}
+}
+
+func TestNextDelay(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    300 * time.Millisecond,
+		MaxElapsed:  10 * time.Second,
+	}
+	status := func(code int, header ...string) *http.Response {
+		resp := &http.Response{StatusCode: code, Header: http.Header{}}
+		if len(header) == 2 {
+			resp.Header.Set(header[0], header[1])
+		}
+		return resp
+	}
+	netErr := errors.New("connection reset")
+
+	tests := []struct {
+		name     string
+		attempt  int
+		started  time.Duration // how long ago the request started
+		method   string
+		resp     *http.Response
+		err      error
+		retry    bool
+		min, max time.Duration
+	}{
+		{"first backoff", 1, 0, http.MethodGet, status(503), nil, true, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"doubled backoff", 2, 0, http.MethodGet, status(502), nil, true, 100 * time.Millisecond, 200 * time.Millisecond},
+		{"capped backoff", 4, 0, http.MethodGet, status(504), nil, true, 150 * time.Millisecond, 300 * time.Millisecond},
+		{"last attempt", 5, 0, http.MethodGet, status(503), nil, false, 0, 0},
+		{"not retryable", 1, 0, http.MethodGet, status(404), nil, false, 0, 0},
+		{"network error", 1, 0, http.MethodGet, nil, netErr, true, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"network error on post", 1, 0, http.MethodPost, nil, netErr, false, 0, 0},
+		{"retry after", 1, 0, http.MethodPost, status(429, "Retry-After", "2"), nil, true, 2 * time.Second, 2 * time.Second},
+		{"elapsed cap", 1, 10 * time.Second, http.MethodGet, status(503), nil, false, 0, 0},
+		{"retry after past cap", 1, 9 * time.Second, http.MethodGet, status(429, "Retry-After", "2"), nil, false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, retry := policy.nextDelay(tt.attempt, time.Now().Add(-tt.started), tt.method, tt.resp, tt.err)
+			if retry != tt.retry {
+				t.Fatalf("retry = %v, want %v", retry, tt.retry)
+			}
+			if retry && (delay < tt.min || delay > tt.max) {
+				t.Errorf("delay = %s, want between %s and %s", delay, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		ok       bool
+		min, max time.Duration
+	}{
+		{"empty", "", false, 0, 0},
+		{"seconds", "3", true, 3 * time.Second, 3 * time.Second},
+		{"zero", "0", true, 0, 0},
+		{"negative", "-1", false, 0, 0},
+		{"garbage", "soon", false, 0, 0},
+		{"http date", time.Now().Add(5 * time.Second).UTC().Format(http.TimeFormat), true, 3 * time.Second, 5 * time.Second},
+		{"past http date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), true, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, ok := retryAfter(tt.value)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if delay < tt.min || delay > tt.max {
+				t.Errorf("delay = %s, want between %s and %s", delay, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	unixReset := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name      string
+		header    http.Header
+		ok        bool
+		limit     int
+		remaining int
+		reset     time.Duration // expected time until the reset, roughly
+	}{
+		{"none", http.Header{}, false, 0, 0, 0},
+		{"x headers", http.Header{
+			"X-Ratelimit-Limit":     {"100"},
+			"X-Ratelimit-Remaining": {"42"},
+			"X-Ratelimit-Reset":     {"30"},
+		}, true, 100, 42, 30 * time.Second},
+		{"standard headers", http.Header{
+			"Ratelimit-Limit":     {"10"},
+			"Ratelimit-Remaining": {"0"},
+			"Ratelimit-Reset":     {strconv.FormatInt(unixReset, 10)},
+		}, true, 10, 0, time.Hour},
+		{"remaining only", http.Header{"X-Ratelimit-Remaining": {"7"}}, true, 0, 7, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, ok := parseRateLimit(tt.header)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if limit.Limit != tt.limit || limit.Remaining != tt.remaining {
+				t.Errorf("limit = %d/%d, want %d/%d", limit.Remaining, limit.Limit, tt.remaining, tt.limit)
+			}
+			if tt.reset == 0 {
+				if !limit.Reset.IsZero() {
+					t.Errorf("reset = %s, want none", limit.Reset)
+				}
+				return
+			}
+			if until := time.Until(limit.Reset); until < tt.reset-5*time.Second || until > tt.reset {
+				t.Errorf("reset in %s, want about %s", until, tt.reset)
+			}
+		})
+	}
+}
+
+func TestClientRecordsRateLimit(t *testing.T) {
+	client, _ := stubServer(t, http.Header{
+		"X-Ratelimit-Limit":     {"60"},
+		"X-Ratelimit-Remaining": {"59"},
+	}, http.StatusOK)
+
+	if _, ok := client.RateLimit(); ok {
+		t.Fatalf("rate limit known before any request")
+	}
+	if err := client.Get(context.Background(), "thing", nil); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	limit, ok := client.RateLimit()
+	if !ok || limit.Limit != 60 || limit.Remaining != 59 {
+		t.Errorf("RateLimit() = %+v, %v; want 59 of 60", limit, ok)
+	}
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strings"
@@ -11,11 +14,8 @@ import (
 )
 
 const (
-	DeviceCodeEndpoint = "https://api.jamlaunch.com/device-auth/request"
-	UserAuthEndpoint   = "https://app.jamlaunch.com/device-auth"
-	DevClientId        = "jamlaunch-addon"
-	UserClientId       = "jam-play"
-	ApiBaseUrl         = "https://api.jamlaunch.com"
+	DevClientId  = "jamlaunch-addon"
+	UserClientId = "jam-play"
 )
 
 type DeviceCodeRequest struct {
@@ -23,24 +23,82 @@ type DeviceCodeRequest struct {
 	Scope    string `json:"scope"`
 }
 
+const (
+	// Defaults from RFC 8628 for servers that omit interval or expires_in.
+	defaultPollInterval = 5 * time.Second
+	defaultCodeLifetime = 10 * time.Minute
+	slowDownIncrement   = 5 * time.Second
+)
+
 type DeviceCodeResponse struct {
 	DeviceCode string `json:"deviceCode"`
 	UserCode   string `json:"userCode"`
+	// Interval and ExpiresIn are in seconds and optional.
+	Interval  int `json:"interval,omitempty"`
+	ExpiresIn int `json:"expiresIn,omitempty"`
+}
+
+// UnmarshalJSON also accepts the snake_case field names used by RFC 8628.
+func (d *DeviceCodeResponse) UnmarshalJSON(data []byte) error {
+	type plain DeviceCodeResponse
+	var resp struct {
+		plain
+		DeviceCodeSnake string `json:"device_code"`
+		UserCodeSnake   string `json:"user_code"`
+		ExpiresInSnake  int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+
+	*d = DeviceCodeResponse(resp.plain)
+	d.DeviceCode = firstNonEmpty(d.DeviceCode, resp.DeviceCodeSnake)
+	d.UserCode = firstNonEmpty(d.UserCode, resp.UserCodeSnake)
+	if d.ExpiresIn == 0 {
+		d.ExpiresIn = resp.ExpiresInSnake
+	}
+	return nil
 }
 
 type CheckAuthResponse struct {
 	AccessToken string `json:"accessKey,omitempty"`
 	AccessState string `json:"state"`
+	// Error carries RFC 8628 poll errors such as "authorization_pending".
+	Error string `json:"error,omitempty"`
 }
 
-func requestUserCode(clientId string, scope string) (*DeviceCodeResponse, error) {
+// status folds the state and error fields into one RFC 8628 style value.
+func (r *CheckAuthResponse) status() string {
+	switch strings.ToLower(firstNonEmpty(r.Error, r.AccessState)) {
+	case "allowed", "approved":
+		return "allowed"
+	case "denied", "access_denied":
+		return "denied"
+	case "expired", "expired_token":
+		return "expired"
+	case "slow_down":
+		return "slow_down"
+	}
+	return "authorization_pending"
+}
+
+func requestUserCode(ctx context.Context, clientId string, scope string) (*DeviceCodeResponse, error) {
 	payload := DeviceCodeRequest{
 		ClientId: clientId,
 		Scope:    scope,
 	}
 	body, _ := json.Marshal(payload)
 
-	resp, err := http.Post(DeviceCodeEndpoint, "application/json", bytes.NewBuffer(body))
+	ctx, cancel := context.WithTimeout(ctx, clientSettings.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, activeEnv.deviceCodeEndpoint(), bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("\033[91mfailed to send request: %w\033[0m", err)
 	}
@@ -59,62 +117,203 @@ func requestUserCode(clientId string, scope string) (*DeviceCodeResponse, error)
 	return &deviceCodeResp, nil
 }
 
-func checkAuth(deviceCodeResp *DeviceCodeResponse) (*CheckAuthResponse, error) {
-	checkURL := fmt.Sprintf("%s/%s/%s", DeviceCodeEndpoint, deviceCodeResp.UserCode, deviceCodeResp.DeviceCode)
+func checkAuth(ctx context.Context, deviceCodeResp *DeviceCodeResponse) (*CheckAuthResponse, error) {
+	checkURL := fmt.Sprintf("%s/%s/%s", activeEnv.deviceCodeEndpoint(), deviceCodeResp.UserCode, deviceCodeResp.DeviceCode)
+
+	interval := defaultPollInterval
+	if deviceCodeResp.Interval > 0 {
+		interval = time.Duration(deviceCodeResp.Interval) * time.Second
+	}
+	lifetime := defaultCodeLifetime
+	if deviceCodeResp.ExpiresIn > 0 {
+		lifetime = time.Duration(deviceCodeResp.ExpiresIn) * time.Second
+	}
+	deadline := time.Now().Add(lifetime)
+
+	countdown := newCountdown(deadline)
+	defer countdown.clear()
 
 	for {
-		// Send GET request
-		resp, err := http.Get(checkURL)
+		// Delay before the next request, updating the countdown meanwhile
+		if err := countdown.wait(ctx, interval); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil, fmt.Errorf("login cancelled: %w", err)
+			}
+			return nil, err
+		}
+
+		authResponse, err := pollAuth(ctx, checkURL)
 		if err != nil {
-			return nil, fmt.Errorf("\033[91mfailed to make request: %w\033[0m", err)
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("login cancelled: %w", ctx.Err())
+			}
+			// Network hiccups and server errors are retried until the code
+			// expires; anything else means the request itself is wrong.
+			var statusErr *pollStatusError
+			if errors.As(err, &statusErr) && statusErr.status < 500 {
+				return nil, err
+			}
+			countdown.note(fmt.Sprintf("poll failed, retrying: %s", err))
+			continue
+		}
+
+		switch authResponse.status() {
+		case "allowed":
+			countdown.clear()
+			fmt.Fprintf(flowOutput(), "\033[92mLogin successful!\033[0m\n")
+			return authResponse, nil
+		case "denied":
+			return nil, fmt.Errorf("login denied")
+		case "expired":
+			return nil, fmt.Errorf("the login code expired before it was approved; run 'login' to get a new code")
+		case "slow_down":
+			interval += slowDownIncrement
 		}
-		defer resp.Body.Close()
+	}
+}
+
+type pollStatusError struct {
+	status int
+	body   string
+}
+
+func (e *pollStatusError) Error() string {
+	return fmt.Sprintf("received non-OK HTTP status: %d %s %s", e.status, http.StatusText(e.status), e.body)
+}
+
+// pollAuth performs a single poll. RFC 8628 servers answer pending polls with
+// 400 and an error code, so 400 responses with a JSON body are decoded too.
+func pollAuth(ctx context.Context, checkURL string) (*CheckAuthResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, clientSettings.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var authResponse CheckAuthResponse
+	decodeErr := json.Unmarshal(body, &authResponse)
 
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("\033[91mreceived non-OK HTTP status: %s\033[0m", resp.Status)
+	if resp.StatusCode == http.StatusBadRequest && decodeErr == nil && authResponse.Error != "" {
+		return &authResponse, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &pollStatusError{status: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
+	}
+
+	return &authResponse, nil
+}
+
+// countdown shows the time left to approve a login on a single, rewritten
+// terminal line. It prints nothing when stdout is not a terminal.
+type countdown struct {
+	deadline time.Time
+	live     bool
+	shown    bool
+}
+
+func newCountdown(deadline time.Time) *countdown {
+	return &countdown{deadline: deadline, live: isTerminal(os.Stdout) && !loginDisplay.json}
+}
+
+// wait sleeps for d, or less if the code expires sooner, refreshing the
+// countdown every second.
+func (c *countdown) wait(ctx context.Context, d time.Duration) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	until := time.Now().Add(d)
+	for {
+		remaining := time.Until(c.deadline)
+		if remaining <= 0 {
+			c.clear()
+			return fmt.Errorf("timed out: the login code expired before it was approved; run 'login' to get a new code")
 		}
+		c.render(remaining)
 
-		// Decode JSON response
-		var authResponse CheckAuthResponse
-		if err := json.NewDecoder(resp.Body).Decode(&authResponse); err != nil {
-			return nil, fmt.Errorf("\033[91mfailed to decode response: %w\033[0m", err)
+		if !time.Now().Before(until) {
+			return nil
 		}
 
-		// Check the state
-		if authResponse.AccessState == "allowed" {
-			fmt.Printf("\033[92mLogin successful!\033[0m\n")
-			return &authResponse, nil
-		} else if authResponse.AccessState == "denied" {
-			return nil, fmt.Errorf("login denied")
+		select {
+		case <-ctx.Done():
+			c.clear()
+			return ctx.Err()
+		case <-ticker.C:
 		}
+	}
+}
 
-		// Delay before the next request
-		time.Sleep(time.Second)
+func (c *countdown) render(remaining time.Duration) {
+	if !c.live {
+		return
 	}
+	remaining = remaining.Round(time.Second)
+	fmt.Printf("\r\033[KWaiting for approval... %d:%02d remaining (Ctrl-C to cancel)", int(remaining.Minutes()), int(remaining.Seconds())%60)
+	c.shown = true
 }
 
-func saveToken(authToken string) error {
-	data := map[string]string{"authToken": authToken}
+func (c *countdown) note(msg string) {
+	c.clear()
+	fmt.Fprintf(flowOutput(), "\033[93m%s\033[0m\n", msg)
+}
 
-	file, err := os.Create("userConfig.json")
-	if err != nil {
-		return fmt.Errorf("\033[91mFailed to create file: %w\033[0m", err)
+func (c *countdown) clear() {
+	if c.shown {
+		fmt.Print("\r\033[K")
+		c.shown = false
 	}
+}
+
+func saveToken(authToken string) error {
+	return saveProfile(func(p *Profile) { p.Token = authToken })
+}
 
-	defer file.Close()
+func savePlayerToken(playerToken string) error {
+	return saveProfile(func(p *Profile) { p.PlayerToken = playerToken })
+}
 
-	encoder := json.NewEncoder(file)
-	if err := encoder.Encode(data); err != nil {
-		return fmt.Errorf("\033[91mFailed to write token to file: %w\033[0m", err)
+// saveProfile applies fn to the active profile, creating it if needed.
+func saveProfile(fn func(p *Profile)) error {
+	err := updateConfig(func(cfg *Config) error {
+		p, ok := cfg.Profiles[activeProfile]
+		if !ok || p == nil {
+			p = &Profile{}
+			cfg.Profiles[activeProfile] = p
+		}
+		fn(p)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("\033[91mFailed to save token: %w\033[0m", err)
 	}
 
 	return nil
 }
 
-func getDevToken() (string, error) {
-	devResp, err := deviceAuthFlow(DevClientId, "developer")
+func getDevToken(ctx context.Context) (string, error) {
+	return getScopedDevToken(ctx, devScope())
+}
+
+func getScopedDevToken(ctx context.Context, scope string) (string, error) {
+	devResp, err := deviceAuthFlow(ctx, DevClientId, scope)
 	if err != nil {
-		return "", fmt.Errorf("failed to get developer token: %v", err)
+		return "", fmt.Errorf("failed to get developer token: %w", err)
 	}
 
 	if err = saveToken(devResp.AccessToken); err != nil {
@@ -124,41 +323,49 @@ func getDevToken() (string, error) {
 	return devResp.AccessToken, nil
 }
 
-func deviceAuthFlow(clientId string, scope string) (*CheckAuthResponse, error) {
-	deviceCodeResp, err := requestUserCode(clientId, scope)
+// getPlayerToken logs in as a player with the jam-play client. The token is
+// stored next to, not instead of, the developer token.
+func getPlayerToken(ctx context.Context, scope string) (string, error) {
+	playResp, err := deviceAuthFlow(ctx, UserClientId, scope)
 	if err != nil {
-		return nil, fmt.Errorf("error requesting user code: %v", err)
+		return "", fmt.Errorf("failed to get player token: %w", err)
+	}
+
+	if err = savePlayerToken(playResp.AccessToken); err != nil {
+		return "", fmt.Errorf("error saving tokens: %v", err)
+	}
+
+	return playResp.AccessToken, nil
+}
+
+func deviceAuthFlow(ctx context.Context, clientId string, scope string) (*CheckAuthResponse, error) {
+	deviceCodeResp, err := requestUserCode(ctx, clientId, scope)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting user code: %w", err)
 	}
 
 	// Step 2: Display User Instructions
-	fmt.Printf("\033[93mVisit:\033[0m %s?user_code=%s\n", UserAuthEndpoint, deviceCodeResp.UserCode)
-	fmt.Printf("\033[93mEnter the code:\033[0m %s\n", deviceCodeResp.UserCode)
+	showDeviceCode(deviceCodeResp)
 
 	// Step 3: Poll for Access Token
-	authResponse, err := checkAuth(deviceCodeResp)
+	authResponse, err := checkAuth(ctx, deviceCodeResp)
 	if err != nil {
-		return nil, fmt.Errorf("error polling for token: %v", err)
+		return nil, fmt.Errorf("error polling for token: %w", err)
 	}
 
 	return authResponse, nil
 }
 
-func getGameUserToken(gameId string, token string) (string, error) {
+func getGameUserToken(ctx context.Context, gameId string, token string) (string, error) {
 	parts := strings.SplitN(gameId, "-", 2)
-	body := map[string]interface{}{
-		"release":  parts[1],
-		"test_num": 99,
+	if len(parts) != 2 {
+		return "", fmt.Errorf("game id %q must have the form <project id>-<release id>", gameId)
 	}
 
-	res, err := apiPost(fmt.Sprintf("%s/projects/%s/testkey", ApiBaseUrl, parts[0]), token, body)
+	key, err := newClient(token).CreateTestKey(ctx, parts[0], parts[1], 99)
 	if err != nil {
-		return "", fmt.Errorf("error getting test token for game: %v", err)
-	}
-
-	token, ok := res["test_jwt"].(string)
-	if !ok {
-		return "", fmt.Errorf("game test token missing from API response")
+		return "", fmt.Errorf("error getting test token for game: %w", err)
 	}
 
-	return token, nil
+	return key.JWT, nil
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// runBatch runs the commands piped to stdin, one per line, as for
+// 'jam-cli < commands.txt'. Nothing is echoed; empty lines and lines starting
+// with # are skipped. 'exit' ends the script early. The script stops at the
+// first failing command unless keepGoing is set, and the exit status is that
+// of the first failure.
+func runBatch(cfg *Config, in io.Reader, keepGoing bool) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	st := &cliState{config: cfg, ownsStdin: true}
+	status := exitOK
+
+	reader := bufio.NewReader(in)
+	for lineNo := 1; ; lineNo++ {
+		text, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			printError(fmt.Errorf("error reading commands: %w", readErr))
+			return exitError
+		}
+
+		line := strings.TrimSpace(text)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			err := runBatchLine(ctx, st, line)
+			if errors.Is(err, errExit) {
+				return status
+			}
+			if err != nil {
+				printError(fmt.Errorf("line %d: %w", lineNo, err))
+				if status == exitOK {
+					status = exitStatus(err)
+				}
+				if !keepGoing || errors.Is(err, context.Canceled) {
+					return status
+				}
+			}
+		}
+
+		if readErr == io.EOF {
+			return status
+		}
+	}
+}
+
+func runBatchLine(ctx context.Context, st *cliState, line string) error {
+	parts, err := splitCommandLine(line)
+	if err != nil {
+		return err
+	}
+	return runArgs(ctx, st, parts)
+}
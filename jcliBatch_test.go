@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunBatch(t *testing.T) {
+	useConfigDir(t)
+
+	tests := []struct {
+		name      string
+		script    string
+		keepGoing bool
+		want      int
+	}{
+		{"all succeed", "# comment\n\nhelp\n", false, exitOK},
+		{"exit ends the script", "help\nexit\nbogus\n", false, exitOK},
+		{"quit ends the script", "quit\nbogus\n", false, exitOK},
+		{"exit keeps the first failure", "bogus\nexit\n", true, exitUsage},
+		{"stops at first failure", "bogus\nexit\n", false, exitUsage},
+		{"no trailing newline", "help", false, exitOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var status int
+			captureStdout(t, func() error {
+				status = runBatch(&Config{}, strings.NewReader(tt.script), tt.keepGoing)
+				return nil
+			})
+			if status != tt.want {
+				t.Errorf("runBatch = %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
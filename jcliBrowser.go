@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// loginDisplay controls how the device login presents its code. The fields
+// start from the config file and the login flags override them for one login.
+var loginDisplay struct {
+	json      bool
+	noBrowser bool
+	noQR      bool
+}
+
+// flowOutput is where the device login writes its progress. In JSON mode
+// stdout carries only the JSON document.
+func flowOutput() io.Writer {
+	if loginDisplay.json {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+type deviceCodeRecord struct {
+	UserCode                string `json:"user_code"`
+	VerificationUri         string `json:"verification_uri"`
+	VerificationUriComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in,omitempty"`
+}
+
+// showDeviceCode tells the user where to approve the login: as JSON, or as
+// text with a QR code of the link, opening the browser when possible.
+func showDeviceCode(resp *DeviceCodeResponse) {
+	link := activeEnv.userAuthEndpoint() + "?user_code=" + url.QueryEscape(resp.UserCode)
+
+	if loginDisplay.json {
+		json.NewEncoder(os.Stdout).Encode(deviceCodeRecord{
+			UserCode:                resp.UserCode,
+			VerificationUri:         activeEnv.userAuthEndpoint(),
+			VerificationUriComplete: link,
+			ExpiresIn:               resp.ExpiresIn,
+		})
+		return
+	}
+
+	fmt.Printf("\033[93mVisit:\033[0m %s\n", link)
+	fmt.Printf("\033[93mEnter the code:\033[0m %s\n", resp.UserCode)
+
+	if !isTerminal(os.Stdout) {
+		return
+	}
+
+	if !loginDisplay.noQR {
+		if qr, err := qrcode.New(link, qrcode.Low); err == nil {
+			fmt.Print(qr.ToSmallString(false))
+		}
+	}
+
+	if !loginDisplay.noBrowser && canOpenBrowser() {
+		if err := openBrowser(link); err == nil {
+			fmt.Println("Opened the login page in your browser (use --no-browser to stop this).")
+		}
+	}
+}
+
+// canOpenBrowser reports whether there is a desktop to open a browser on,
+// which is not the case over SSH or on headless machines.
+func canOpenBrowser() bool {
+	if os.Getenv("SSH_CONNECTION") != "" {
+		return false
+	}
+	if runtime.GOOS == "linux" || runtime.GOOS == "freebsd" {
+		return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+	}
+	return true
+}
+
+func openBrowser(link string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", link)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+	default:
+		cmd = exec.Command("xdg-open", link)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
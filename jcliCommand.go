@@ -1,359 +1,429 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
+	"strconv"
+	"time"
 
+	"github.com/jam-launch/jam-cli/jamlaunch"
 	"github.com/jedib0t/go-pretty/table"
 )
 
-func login() error {
-	fmt.Println("Requesting new tokens...")
+// login runs the device flow for a developer or player token. An empty scope
+// requests the profile's usual one.
+func login(ctx context.Context, asPlayer bool, scope string) (string, error) {
+	if !canLogin() {
+		return "", fmt.Errorf("%w: browser login is disabled in CI or when %s is set; use 'login --with-token'", errLoginRequired, tokenEnvVar)
+	}
+
+	requested := normalizeScopes(scope)
+	if requested != "" {
+		if err := validateScopes(ctx, requested); err != nil {
+			return "", err
+		}
+	}
 
-	_, err := getDevToken()
+	fmt.Fprintln(flowOutput(), "Requesting new tokens...")
+
+	if asPlayer {
+		requested = firstNonEmpty(requested, defaultPlayerScope)
+		token, err := getPlayerToken(ctx, requested)
+		if err != nil {
+			return "", fmt.Errorf("error: failed to login as player: %v", err)
+		}
+		printGrantedScopes(token, requested)
+		return token, nil
+	}
+
+	remember := requested != ""
+	requested = firstNonEmpty(requested, devScope())
+
+	token, err := getScopedDevToken(ctx, requested)
 	if err != nil {
-		return fmt.Errorf("error: failed to login: %v", err)
+		return "", fmt.Errorf("error: failed to login: %v", err)
+	}
+	if remember {
+		if err := saveProfile(func(p *Profile) { p.Scope = requested }); err != nil {
+			return "", err
+		}
 	}
+	printGrantedScopes(token, requested)
 
-	return nil
+	return token, nil
 }
 
-func apiGet(p string, authToken string) error {
-	var apiUrl = "https://api.jamlaunch.com/" + p
-
-	data, success := fetch(apiUrl, authToken)
+func apiGet(ctx context.Context, p string, authToken string) error {
+	data, success := fetch(ctx, p, authToken)
 	if success == nil {
 		jsonBytes, err := json.MarshalIndent(data, "", "  ")
 		if err != nil {
-			//log.Printf("\033[91mError: failed to format response as JSON: %v\033[0m\n", err)
 			return fmt.Errorf("error: failed to format response as json: %v", err)
 		}
 
 		jsonString := string(jsonBytes)
 		fmt.Println(jsonString)
 	} else {
-		//fmt.Printf("\033[91m%s\033[0m\n", success)
-		return fmt.Errorf("error: %s", success)
+		return fmt.Errorf("error: %w", success)
 	}
 
 	return nil
 }
 
-func projects(authToken string) error {
-	var apiUrl = "https://api.jamlaunch.com/projects"
+func projects(ctx context.Context, st *cliState, format string) error {
+	projects, err := newClient(st.token).ListProjects(ctx)
+	if err != nil {
+		return fmt.Errorf("error: unable to retrieve projects: %w", err)
+	}
+	st.projects.remember(st.token, projects)
+
+	if format != formatTable {
+		records := []projectRecord{}
+		rows := [][]string{}
+		for _, project := range projects {
+			records = append(records, projectRecord{ID: project.ID, Name: project.Name})
+			rows = append(rows, []string{project.ID, project.Name})
+		}
+		return emit(format, records, []string{"id", "name"}, rows)
+	}
 
-	data, success := fetch(apiUrl, authToken)
+	if len(projects) == 0 {
+		fmt.Println("You currently do not have any projects!")
+		return nil
+	}
 
-	if success == nil {
-		if projects, ok := data["projects"].([]interface{}); ok {
-			if len(projects) == 0 {
-				fmt.Println("You currently do not have any projects!")
-			} else {
-				var (
-					colProjectIndex = "Id"
-					colProjectName  = "Project Name"
-					projectHeader   = table.Row{colProjectIndex, colProjectName}
-				)
-
-				t := table.NewWriter()
-				tTemp := table.Table{}
-				tTemp.Render()
-				t.AppendHeader(projectHeader)
-				t.SetTitle("Current Projects")
-				t.SetStyle(table.StyleColoredDark)
-
-				for _, project := range projects {
-					if projMap, ok := project.(map[string]interface{}); ok {
-						t.AppendRow(table.Row{projMap["id"], projMap["project_name"]})
-					}
-				}
-
-				fmt.Println(t.Render())
-			}
-		} else {
-			return fmt.Errorf("error: projects is not an array, please visit https://app.jamlaunch.com/projects and try again")
-		}
-	} else {
-		return fmt.Errorf("%s", success)
+	var (
+		colProjectIndex = "Id"
+		colProjectName  = "Project Name"
+		projectHeader   = table.Row{colProjectIndex, colProjectName}
+	)
+
+	t := table.NewWriter()
+	t.AppendHeader(projectHeader)
+	t.SetTitle("Current Projects")
+	t.SetStyle(table.StyleColoredDark)
+
+	for _, project := range projects {
+		t.AppendRow(table.Row{project.ID, project.Name})
 	}
 
+	fmt.Println(t.Render())
+
 	return nil
 }
 
-func projectsName(authToken string, name string) error {
-	var apiUrlName = "https://api.jamlaunch.com/projects"
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
 
-	nameData, successName := fetch(apiUrlName, authToken)
+func getProject(ctx context.Context, st *cliState, ref projectRef) (*jamlaunch.Project, error) {
+	client := newClient(st.token)
 
-	if successName == nil && nameData != nil {
-		var projectId string
+	var project *jamlaunch.Project
+	err := st.projects.withRef(ctx, client, ref, func(projectId string) (err error) {
+		project, err = client.GetProject(ctx, projectId)
+		if err != nil {
+			return fmt.Errorf("error: unable to retrieve project data: %w", err)
+		}
+		return nil
+	})
+	return project, err
+}
 
-		if projects, ok := nameData["projects"].([]interface{}); ok {
-			for _, p := range projects {
-				project := p.(map[string]interface{})
+func projectsName(ctx context.Context, st *cliState, name string, format string) error {
+	project, err := getProject(ctx, st, projectRef{query: name})
+	if err != nil {
+		return err
+	}
 
-				if project["project_name"].(string) == name {
-					projectId = project["id"].(string)
-					break
-				}
-			}
+	if format != formatTable {
+		record := newProjectRecord(project)
+		row := []string{record.ID, record.Name, record.CreatedAt, strconv.FormatBool(project.Active)}
+		return emit(format, record, []string{"id", "name", "created_at", "active"}, [][]string{row})
+	}
+
+	fmt.Printf("\033[93mProject Name:\033[0m %s\n", project.Name)
+	fmt.Printf("\033[93mCreated At:\033[0m %s\n", project.CreatedAt.Format("2006-01-02"))
+	fmt.Printf("\033[93mProject Id:\033[0m %s\n", project.ID)
+	fmt.Printf("\033[93mActive:\033[0m %t\n", project.Active)
+	fmt.Println("")
+
+	if len(project.Members) > 0 {
+		printMembers(project.Members)
+	}
+
+	if len(project.Releases) > 0 {
+		fmt.Println("")
+		printReleases(project.Releases)
+	}
+
+	return nil
+}
+
+func projectMembers(ctx context.Context, st *cliState, ref projectRef, format string) error {
+	project, err := getProject(ctx, st, ref)
+	if err != nil {
+		return err
+	}
+
+	if format != formatTable {
+		records := newProjectRecord(project).Members
+		rows := [][]string{}
+		for _, record := range records {
+			rows = append(rows, []string{record.Username, record.Level})
 		}
+		return emit(format, records, []string{"username", "level"}, rows)
+	}
 
-		var apiUrlId = "https://api.jamlaunch.com/projects/" + projectId
-
-		data, successId := fetch(apiUrlId, authToken)
-
-		if successId == nil {
-			if data != nil && data["project_name"] != nil {
-				fmt.Printf("\033[93mProject Name:\033[0m %s\n", data["project_name"].(string))
-				fmt.Printf("\033[93mCreated At:\033[0m %s\n", data["created_at"].(string)[:10])
-				fmt.Printf("\033[93mProject Id:\033[0m %s\n", data["id"].(string))
-				fmt.Printf("\033[93mActive:\033[0m %t\n", data["active"].(bool))
-				fmt.Println("")
-
-				if members, ok := data["members"].([]interface{}); ok && len(members) > 0 {
-					var (
-						colUsername   = "Username"
-						colLevel      = "Level"
-						membersHeader = table.Row{colUsername, colLevel}
-					)
-
-					t := table.NewWriter()
-					tTemp := table.Table{}
-					tTemp.Render()
-					t.AppendHeader(membersHeader)
-					t.SetTitle("Current Members")
-					t.SetStyle(table.StyleColoredDark)
-
-					for _, member := range members {
-						if memMap, ok := member.(map[string]interface{}); ok {
-							t.AppendRow(table.Row{memMap["username"], memMap["level"]})
-						}
-					}
-
-					fmt.Println(t.Render())
-				}
-
-				if releases, ok := data["releases"].([]interface{}); ok && len(releases) > 0 {
-					fmt.Println("")
-
-					var (
-						colId             = "id"
-						colCreatedAt      = "Created At"
-						colDefaultRelease = "Default Release"
-						colPublic         = "Public"
-						colNetworkMode    = "Network Mode"
-						colServerBuild    = "Server Build"
-						colAllowGuests    = "Allow Guests"
-						releasesHeader    = table.Row{colId, colCreatedAt, colDefaultRelease, colPublic, colNetworkMode, colServerBuild, colAllowGuests}
-					)
-
-					t := table.NewWriter()
-					tTemp := table.Table{}
-					tTemp.Render()
-					t.AppendHeader(releasesHeader)
-					t.SetTitle("Current Releases")
-					t.SetStyle(table.StyleColoredDark)
-
-					for _, release := range releases {
-						if relMap, ok := release.(map[string]interface{}); ok {
-							t.AppendRow(table.Row{
-								relMap["id"],
-								relMap["created_at"],
-								relMap["is_default"],
-								relMap["public"],
-								relMap["network_mode"],
-								relMap["server_build"],
-								relMap["allow_guests"],
-							})
-						}
-					}
-
-					fmt.Println(t.Render())
-				}
-			} else {
-				return fmt.Errorf("error: project not found")
-			}
-		} else {
-			return fmt.Errorf("error: unable to retrieve project data: %s", successId)
+	if len(project.Members) == 0 {
+		fmt.Printf("This project currently has no members!\n")
+		return nil
+	}
+
+	printMembers(project.Members)
+	return nil
+}
+
+func projectReleases(ctx context.Context, st *cliState, ref projectRef, format string) error {
+	project, err := getProject(ctx, st, ref)
+	if err != nil {
+		return err
+	}
+
+	if format != formatTable {
+		records := newProjectRecord(project).Releases
+		rows := [][]string{}
+		for _, record := range records {
+			rows = append(rows, []string{
+				record.ID,
+				record.CreatedAt,
+				strconv.FormatBool(record.IsDefault),
+				strconv.FormatBool(record.Public),
+				record.NetworkMode,
+				strconv.FormatBool(record.ServerBuild),
+				strconv.FormatBool(record.AllowGuests),
+			})
 		}
-	} else {
-		return fmt.Errorf("error: unable to retrieve projects: %s", successName)
+		return emit(format, records, []string{"id", "created_at", "is_default", "public", "network_mode", "server_build", "allow_guests"}, rows)
+	}
+
+	if len(project.Releases) == 0 {
+		fmt.Printf("This project currently has no releases!\n")
+		return nil
 	}
 
+	printReleases(project.Releases)
 	return nil
 }
 
-func projectSessions(authToken string, name string) error {
-	var apiUrlName = "https://api.jamlaunch.com/projects"
+func printMembers(members []jamlaunch.Member) {
+	var (
+		colUsername   = "Username"
+		colLevel      = "Level"
+		membersHeader = table.Row{colUsername, colLevel}
+	)
 
-	nameData, successName := fetch(apiUrlName, authToken)
+	t := table.NewWriter()
+	t.AppendHeader(membersHeader)
+	t.SetTitle("Current Members")
+	t.SetStyle(table.StyleColoredDark)
 
-	if successName == nil && nameData != nil {
-		var projectId string
+	for _, member := range members {
+		t.AppendRow(table.Row{member.Username, member.Level})
+	}
 
-		if projects, ok := nameData["projects"].([]interface{}); ok {
-			for _, p := range projects {
-				project := p.(map[string]interface{})
+	fmt.Println(t.Render())
+}
 
-				if project["project_name"].(string) == name {
-					projectId = project["id"].(string)
-					break
-				}
-			}
-		}
+func printReleases(releases []jamlaunch.Release) {
+	var (
+		colId             = "id"
+		colCreatedAt      = "Created At"
+		colDefaultRelease = "Default Release"
+		colPublic         = "Public"
+		colNetworkMode    = "Network Mode"
+		colServerBuild    = "Server Build"
+		colAllowGuests    = "Allow Guests"
+		releasesHeader    = table.Row{colId, colCreatedAt, colDefaultRelease, colPublic, colNetworkMode, colServerBuild, colAllowGuests}
+	)
+
+	t := table.NewWriter()
+	t.AppendHeader(releasesHeader)
+	t.SetTitle("Current Releases")
+	t.SetStyle(table.StyleColoredDark)
+
+	for _, release := range releases {
+		t.AppendRow(table.Row{
+			release.ID,
+			formatTime(release.CreatedAt),
+			release.IsDefault,
+			release.Public,
+			release.NetworkMode,
+			release.ServerBuild,
+			release.AllowGuests,
+		})
+	}
 
-		var apiUrlSessions = "https://api.jamlaunch.com/projects/" + projectId + "/sessions"
-
-		data, successId := fetch(apiUrlSessions, authToken)
-
-		if successId == nil {
-			if data != nil {
-				if sessions, ok := data["sessions"].([]interface{}); ok && len(sessions) > 0 {
-					var (
-						colSessionId        = "Id"
-						colAddress          = "Address"
-						colSessionCreatedAt = "Created At"
-						colState            = "State"
-						sessionsHeader      = table.Row{colSessionId, colAddress, colSessionCreatedAt, colState}
-					)
-
-					t := table.NewWriter()
-					tTemp := table.Table{}
-					tTemp.Render()
-					t.AppendHeader(sessionsHeader)
-					t.SetTitle("Current Sessions")
-					t.SetStyle(table.StyleColoredDark)
-
-					for _, session := range sessions {
-						if memMap, ok := session.(map[string]interface{}); ok {
-							t.AppendRow(table.Row{memMap["id"], memMap["address"], memMap["createdAt"], memMap["state"]})
-						}
-					}
-
-					fmt.Println(t.Render())
-				} else {
-					fmt.Printf("This project currently has no sessions!\n")
-				}
-			} else {
-				fmt.Printf("This project currently has no sessions!\n")
-			}
-		} else {
-			return fmt.Errorf("error: unable to retrieve session data: %s", successId)
+	fmt.Println(t.Render())
+}
+
+// useProject makes query the current project of the active profile. The
+// project's id is stored so later commands need not look it up, and its name
+// so the prompt can show it without an API call.
+func useProject(ctx context.Context, st *cliState, query string) error {
+	project, err := st.projects.resolve(ctx, newClient(st.token), query)
+	if err != nil {
+		return err
+	}
+
+	err = updateConfig(func(cfg *Config) error {
+		p, ok := cfg.Profiles[activeProfile]
+		if !ok || p == nil {
+			p = &Profile{}
+			cfg.Profiles[activeProfile] = p
 		}
-	} else {
-		return fmt.Errorf("error: unable to retrieve projects: %s", successName)
+		p.Project = project.ID
+		p.ProjectName = project.Name
+		st.config = cfg
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	fmt.Printf("\033[92mNow using project %s\033[0m (%s)\n", project.Name, project.ID)
 	return nil
 }
 
-func projectSessionId(authToken string, name string, sessionId string) error {
-	var apiUrlName = "https://api.jamlaunch.com/projects"
-
-	nameData, successName := fetch(apiUrlName, authToken)
+func clearProject(st *cliState) error {
+	err := updateConfig(func(cfg *Config) error {
+		if p, ok := cfg.Profiles[activeProfile]; ok && p != nil {
+			p.Project = ""
+			p.ProjectName = ""
+		}
+		st.config = cfg
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-	if successName == nil && nameData != nil {
-		var projectId string
+	fmt.Println("No project selected.")
+	return nil
+}
 
-		if projects, ok := nameData["projects"].([]interface{}); ok {
-			for _, p := range projects {
-				project := p.(map[string]interface{})
+func projectSessions(ctx context.Context, st *cliState, ref projectRef, format string) error {
+	client := newClient(st.token)
 
-				if project["project_name"].(string) == name {
-					projectId = project["id"].(string)
-					break
-				}
-			}
+	var sessions []jamlaunch.SessionSummary
+	err := st.projects.withRef(ctx, client, ref, func(projectId string) (err error) {
+		sessions, err = client.ListSessions(ctx, projectId)
+		if err != nil {
+			return fmt.Errorf("error: unable to retrieve session data: %w", err)
 		}
+		st.projects.rememberSessions(projectId, sessions)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		var apiUrlSessionsWithId = "https://api.jamlaunch.com/projects/" + projectId + "/sessions/" + sessionId
-
-		data, successId := fetch(apiUrlSessionsWithId, authToken)
-
-		if successId == nil {
-			if id, ok := data["id"]; ok {
-				fmt.Printf("\033[93mSession Id:\033[0m %s\n", id.(string))
-				fmt.Printf("\033[93mSession Address:\033[0m %s\n", data["address"].(string))
-				fmt.Printf("\033[93mSession Join Code:\033[0m %s\n", data["joinCode"].(string))
-				fmt.Printf("\033[93mSession Region:\033[0m %s\n", data["region"].(string))
-				fmt.Printf("\033[93mSession State:\033[0m %s\n", data["state"].(string))
-				fmt.Println("")
-
-				if players, ok := data["players"].([]interface{}); ok && len(players) > 0 {
-					var (
-						colPlayerUsername = "Username"
-						colHost           = "Host"
-						playerHeader      = table.Row{colPlayerUsername, colHost}
-					)
-
-					t := table.NewWriter()
-					tTemp := table.Table{}
-					tTemp.Render()
-					t.AppendHeader(playerHeader)
-					t.SetTitle("Current Players")
-					t.SetStyle(table.StyleColoredDark)
-
-					for _, player := range players {
-						if memMap, ok := player.(map[string]interface{}); ok {
-							t.AppendRow(table.Row{memMap["username"], memMap["host"]})
-						}
-					}
-
-					fmt.Println(t.Render())
-				} else {
-					fmt.Printf("This session has no players!\n")
-				}
-			} else {
-				return fmt.Errorf("error: unable to find session or session does not exist")
+	if format != formatTable {
+		records := []sessionRecord{}
+		rows := [][]string{}
+		for _, session := range sessions {
+			record := sessionRecord{
+				ID:        session.ID,
+				Address:   session.Address,
+				CreatedAt: formatTime(session.CreatedAt),
+				State:     session.State,
 			}
-		} else {
-			return fmt.Errorf("error: unable to retrieve session data: %s", successId)
+			records = append(records, record)
+			rows = append(rows, []string{record.ID, record.Address, record.CreatedAt, record.State})
 		}
-	} else {
-		return fmt.Errorf("error: unable to retrieve projects: %s", successName)
+		return emit(format, records, []string{"id", "address", "created_at", "state"}, rows)
 	}
 
+	if len(sessions) == 0 {
+		fmt.Printf("This project currently has no sessions!\n")
+		return nil
+	}
+
+	var (
+		colSessionId        = "Id"
+		colAddress          = "Address"
+		colSessionCreatedAt = "Created At"
+		colState            = "State"
+		sessionsHeader      = table.Row{colSessionId, colAddress, colSessionCreatedAt, colState}
+	)
+
+	t := table.NewWriter()
+	t.AppendHeader(sessionsHeader)
+	t.SetTitle("Current Sessions")
+	t.SetStyle(table.StyleColoredDark)
+
+	for _, session := range sessions {
+		t.AppendRow(table.Row{session.ID, session.Address, formatTime(session.CreatedAt), session.State})
+	}
+
+	fmt.Println(t.Render())
+
 	return nil
 }
 
-func help(input string) {
-	parts := strings.Fields(input)
-
-	if len(parts) == 1 && strings.ToLower(parts[0]) == "help" {
-		fmt.Println("For more information on a specific command, type HELP command-name")
-		fmt.Println("HELP        Provides Help information for Jam Launch CLI commands.")
-		fmt.Println("LOGIN       Prompts the user to log in again.")
-		fmt.Println("PROJECTS    Displays a list of the users current projects.")
-	} else if len(parts) == 2 && strings.ToLower(parts[0]) == "help" && strings.ToLower(parts[1]) == "projects" {
-		fmt.Println("PROJECTS command details:")
-		fmt.Println("Displays a list of the user's current projects.")
-		fmt.Println("")
-		fmt.Println("PROJECTS (No Parameters)")
-		fmt.Println("PROJECTS (Project Name)")
-		fmt.Println("PROJECTS (Project Name) SESSIONS")
-		fmt.Println("PROJECTS (Project Name) SESSIONS (Session ID)")
-		fmt.Println("")
-		fmt.Println("This command will display the id and name of each project in a table format.")
-		fmt.Println("Running projects with parameters will display more specific details about a specific project.")
-		fmt.Println("Running projects with parameters and the \"sessions\" keyword will display session information about the project")
-	} else if len(parts) == 2 && strings.ToLower(parts[0]) == "help" && strings.ToLower(parts[1]) == "help" {
-		fmt.Println("HELP command details:")
-		fmt.Println("Provides Help information for Jam Launch CLI commands.")
-		fmt.Println("")
-		fmt.Println("HELP (No Parameters)")
-		fmt.Println("HELP [Command Name]")
-		fmt.Println("")
-		fmt.Println("Running help with parameters will display detailed help information for the command specified by the parameter.")
-	} else if len(parts) == 2 && strings.ToLower(parts[0]) == "help" && strings.ToLower(parts[1]) == "login" {
-		fmt.Println("LOGIN command details:")
-		fmt.Println("Prompts the user to log in again.")
-		fmt.Println("")
-		fmt.Println("LOGIN (No Parameters)")
-		fmt.Println("")
-		fmt.Println("Running this command will prompt the user to generate a new authentication token and replace the old one regardles if it is valid or not.")
-	} else {
-		fmt.Printf("\033[31mCommand formatted incorrectly. Use 'HELP' or 'HELP command-name'.\033[0m\n")
+func projectSessionId(ctx context.Context, st *cliState, ref projectRef, sessionId string, format string) error {
+	client := newClient(st.token)
+
+	var session *jamlaunch.Session
+	err := st.projects.withRef(ctx, client, ref, func(projectId string) (err error) {
+		session, err = client.GetSession(ctx, projectId, sessionId)
+		if err != nil {
+			return fmt.Errorf("error: unable to retrieve session data: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if format != formatTable {
+		record := newSessionRecord(session)
+		row := []string{record.ID, record.Address, record.JoinCode, record.Region, record.State}
+		return emit(format, record, []string{"id", "address", "join_code", "region", "state"}, [][]string{row})
+	}
+
+	fmt.Printf("\033[93mSession Id:\033[0m %s\n", session.ID)
+	fmt.Printf("\033[93mSession Address:\033[0m %s\n", session.Address)
+	fmt.Printf("\033[93mSession Join Code:\033[0m %s\n", session.JoinCode)
+	fmt.Printf("\033[93mSession Region:\033[0m %s\n", session.Region)
+	fmt.Printf("\033[93mSession State:\033[0m %s\n", session.State)
+	fmt.Println("")
+
+	if len(session.Players) == 0 {
+		fmt.Printf("This session has no players!\n")
+		return nil
 	}
+
+	var (
+		colPlayerUsername = "Username"
+		colHost           = "Host"
+		playerHeader      = table.Row{colPlayerUsername, colHost}
+	)
+
+	t := table.NewWriter()
+	t.AppendHeader(playerHeader)
+	t.SetTitle("Current Players")
+	t.SetStyle(table.StyleColoredDark)
+
+	for _, player := range session.Players {
+		t.AppendRow(table.Row{player.Username, player.Host})
+	}
+
+	fmt.Println(t.Render())
+
+	return nil
 }
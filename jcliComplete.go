@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// completionTimeout bounds the API calls made to fill the project and
+// session caches from a Tab press, so a slow server cannot hang the prompt.
+const completionTimeout = 5 * time.Second
+
+// completionFunc suggests values for an argument or flag. args holds the
+// positional arguments typed before the one being completed.
+type completionFunc func(st *cliState, args []string) []string
+
+// lineCompleter completes the word under the cursor at the interactive
+// prompt: command names first, then flags, keywords and argument values as
+// described by the command's specs.
+type lineCompleter struct {
+	st *cliState
+}
+
+// Do implements readline.AutoCompleter. It returns what should be inserted
+// after the cursor for each candidate, and how many runes of the word being
+// completed have already been typed.
+func (c *lineCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	scan := scanCommandLine(line[:pos])
+
+	words := []string{}
+	for _, w := range scan.words {
+		words = append(words, w.text)
+	}
+
+	typed := ""
+	if scan.open {
+		last := scan.words[len(scan.words)-1]
+		typed = string(line[last.start:pos])
+		words = words[:len(words)-1]
+	}
+
+	suffixes := [][]rune{}
+	for _, candidate := range c.candidates(words, typed) {
+		quoted := quoteCompletion(candidate, typed)
+		if strings.HasPrefix(quoted, typed) {
+			suffixes = append(suffixes, []rune(quoted[len(typed):]+" "))
+		}
+	}
+
+	return suffixes, len([]rune(typed))
+}
+
+func (c *lineCompleter) candidates(words []string, typed string) []string {
+	if len(words) == 0 {
+		return completeCommands(c.st, nil)
+	}
+
+	cmd := findCommand(words[0])
+	if cmd == nil {
+		return nil
+	}
+	rest := words[1:]
+
+	if len(rest) > 0 {
+		if spec := valueFlag(cmd, rest[len(rest)-1]); spec != nil {
+			if spec.complete == nil {
+				return nil
+			}
+			return spec.complete(c.st, positionalWords(cmd, rest))
+		}
+	}
+
+	if strings.HasPrefix(typed, "-") {
+		flags := []string{}
+		for _, spec := range cmd.flags {
+			flags = append(flags, "--"+spec.name)
+		}
+		return flags
+	}
+
+	positional := positionalWords(cmd, rest)
+	if len(positional) >= len(cmd.args) {
+		return nil
+	}
+
+	arg := cmd.args[len(positional)]
+	switch {
+	case arg.keyword:
+		return []string{arg.name}
+	case len(arg.choices) > 0:
+		return arg.choices
+	case arg.complete != nil:
+		return arg.complete(c.st, positional)
+	}
+	return nil
+}
+
+// valueFlag returns the flag named by word when it still waits for its value
+// in the next word.
+func valueFlag(cmd *command, word string) *flagSpec {
+	if len(word) < 2 || word[0] != '-' || strings.Contains(word, "=") {
+		return nil
+	}
+	spec := cmd.findFlag(strings.TrimLeft(word, "-"))
+	if spec == nil || spec.value == "" {
+		return nil
+	}
+	return spec
+}
+
+// positionalWords drops flags and their values from words the same way
+// parseArgs does, without reporting errors for unfinished lines.
+func positionalWords(cmd *command, words []string) []string {
+	positional := []string{}
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if word == "--" {
+			return append(positional, words[i+1:]...)
+		}
+		if len(word) < 2 || word[0] != '-' {
+			positional = append(positional, word)
+			continue
+		}
+		if valueFlag(cmd, word) != nil {
+			i++
+		}
+	}
+	return positional
+}
+
+// quoteCompletion writes candidate the way it has to be typed, continuing the
+// quoting style of what the user started typing.
+func quoteCompletion(candidate string, typed string) string {
+	switch {
+	case strings.HasPrefix(typed, "\""):
+		escaped := strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(candidate)
+		return "\"" + escaped + "\""
+	case strings.HasPrefix(typed, "'") && !strings.Contains(candidate, "'"):
+		return "'" + candidate + "'"
+	}
+
+	var b strings.Builder
+	for _, r := range candidate {
+		if isLineSpace(r) || r == '"' || r == '\'' || r == '\\' {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func completeCommands(st *cliState, args []string) []string {
+	names := []string{}
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	return names
+}
+
+func completeProfiles(st *cliState, args []string) []string {
+	return profileNames(st.config)
+}
+
+func completeEnvironments(st *cliState, args []string) []string {
+	return environmentNames(st.config)
+}
+
+// completeProjects suggests project names from the session's project cache,
+// filling it first if this is the first use.
+func completeProjects(st *cliState, args []string) []string {
+	if st.token == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+
+	projects, err := st.projects.list(ctx, newClient(st.token))
+	if err != nil {
+		return nil
+	}
+
+	names := []string{}
+	for _, p := range projects {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// completeCurrentSessions suggests the session ids of the current project.
+func completeCurrentSessions(st *cliState, args []string) []string {
+	project, ok := st.config.profile(activeProfile).currentProject()
+	if !ok {
+		return nil
+	}
+	return sessionIds(st, project)
+}
+
+// completeSessions suggests the session ids of the project named in args[0],
+// as last listed by 'projects <project> sessions' or fetched now.
+func completeSessions(st *cliState, args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	return sessionIds(st, projectRef{query: args[0]})
+}
+
+func sessionIds(st *cliState, ref projectRef) []string {
+	if st.token == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+
+	client := newClient(st.token)
+	projectId := ref.id
+	if projectId == "" {
+		project, err := st.projects.resolve(ctx, client, ref.query)
+		if err != nil {
+			return nil
+		}
+		projectId = project.ID
+	}
+
+	if ids, ok := st.projects.sessions[projectId]; ok {
+		return ids
+	}
+
+	sessions, err := client.ListSessions(ctx, projectId)
+	if err != nil {
+		return nil
+	}
+	st.projects.rememberSessions(projectId, sessions)
+	return st.projects.sessions[projectId]
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jam-launch/jam-cli/jamlaunch"
+)
+
+const (
+	DefaultEnvironment = "prod"
+	CustomEnvironment  = "custom"
+)
+
+type Environment struct {
+	Name       string `json:"-"`
+	ApiBaseUrl string `json:"api_url"`
+	AppBaseUrl string `json:"app_url,omitempty"`
+	// JwksUrl defaults to <api_url>/.well-known/jwks.json.
+	JwksUrl string `json:"jwks_url,omitempty"`
+	// Issuer and Audience, when set, must match the token's iss and aud.
+	// PlayerAudience is the aud expected of player tokens instead.
+	Issuer         string `json:"issuer,omitempty"`
+	Audience       string `json:"audience,omitempty"`
+	PlayerAudience string `json:"player_audience,omitempty"`
+}
+
+type Config struct {
+	Environment  string                 `json:"environment,omitempty"`
+	Environments map[string]Environment `json:"environments,omitempty"`
+	Output       string                 `json:"output,omitempty"`
+	Retry        *RetryConfig           `json:"retry,omitempty"`
+	Timeout      string                 `json:"timeout,omitempty"`
+	ClockSkew    string                 `json:"clock_skew,omitempty"`
+	NoBrowser    bool                   `json:"no_browser,omitempty"`
+	NoQR         bool                   `json:"no_qr,omitempty"`
+	Profile      string                 `json:"profile,omitempty"`
+	Profiles     map[string]*Profile    `json:"profiles,omitempty"`
+
+	// AuthToken is the single token stored before profiles existed. It is
+	// moved into the default profile when the config is loaded.
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+// RetryConfig overrides fields of jamlaunch.DefaultRetryPolicy. Durations use
+// Go syntax, e.g. "500ms" or "30s".
+type RetryConfig struct {
+	MaxAttempts *int   `json:"max_attempts,omitempty"`
+	BaseDelay   string `json:"base_delay,omitempty"`
+	MaxDelay    string `json:"max_delay,omitempty"`
+	MaxElapsed  string `json:"max_elapsed,omitempty"`
+}
+
+// builtinEnvironments leave Issuer and Audience unset: the values JamLaunch
+// puts in iss and aud are not documented, and a wrong guess would reject
+// every token. Set them in the config file to enable the checks.
+var builtinEnvironments = map[string]Environment{
+	"prod": {
+		ApiBaseUrl: "https://api.jamlaunch.com",
+		AppBaseUrl: "https://app.jamlaunch.com",
+	},
+	"staging": {
+		ApiBaseUrl: "https://api.staging.jamlaunch.com",
+		AppBaseUrl: "https://app.staging.jamlaunch.com",
+	},
+}
+
+// activeEnv is the environment every request is built against. It is chosen
+// by selectEnvironment at startup and whenever the active profile changes.
+var activeEnv = func() Environment {
+	env := builtinEnvironments[DefaultEnvironment]
+	env.Name = DefaultEnvironment
+	return env
+}()
+
+// forPlayer returns the environment as it applies to player tokens, which are
+// issued to a different client and so carry a different audience.
+func (e Environment) forPlayer() Environment {
+	e.Audience = e.PlayerAudience
+	return e
+}
+
+func (e Environment) apiUrl(path string) string {
+	return e.ApiBaseUrl + "/" + strings.TrimPrefix(path, "/")
+}
+
+func (e Environment) deviceCodeEndpoint() string {
+	return e.apiUrl("device-auth/request")
+}
+
+func (e Environment) userAuthEndpoint() string {
+	return e.AppBaseUrl + "/device-auth"
+}
+
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "jam-cli"), nil
+}
+
+func configPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// loadConfig reads config.json from the config directory. A missing file is
+// not an error and yields an empty config. Changes are written back with
+// updateConfig.
+func loadConfig() (*Config, error) {
+	cfg := &Config{
+		Environments: map[string]Environment{},
+		Profiles:     map[string]*Profile{},
+	}
+
+	path, err := configPath()
+	if err != nil {
+		return cfg, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	// The config holds credentials, so keep it private even if it was
+	// created or copied with looser permissions.
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0077 != 0 {
+		os.Chmod(path, 0600)
+	}
+
+	environments := make(map[string]Environment, len(cfg.Environments))
+	for name, env := range cfg.Environments {
+		environments[strings.ToLower(name)] = env
+	}
+	cfg.Environments = environments
+
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]*Profile{}
+	}
+	if cfg.AuthToken != "" {
+		if _, ok := cfg.Profiles[DefaultProfile]; !ok {
+			cfg.Profiles[DefaultProfile] = &Profile{Token: cfg.AuthToken}
+		}
+		cfg.AuthToken = ""
+	}
+
+	return cfg, nil
+}
+
+// envOverrides holds the --env, --api-url and --app-url flags, which take
+// precedence over the environment stored in the config.
+var envOverrides struct {
+	name   string
+	apiUrl string
+	appUrl string
+}
+
+// selectEnvironment resolves the environment to use. The name comes from the
+// --env flag, then JAMLAUNCH_ENV, then the active profile, then the config
+// file, falling back to prod. An explicit API URL (flag or JAMLAUNCH_API_URL)
+// always selects the custom environment.
+func selectEnvironment(cfg *Config, profile *Profile) (Environment, error) {
+	apiUrl := firstNonEmpty(envOverrides.apiUrl, os.Getenv("JAMLAUNCH_API_URL"))
+	appUrl := firstNonEmpty(envOverrides.appUrl, os.Getenv("JAMLAUNCH_APP_URL"))
+
+	name := firstNonEmpty(envOverrides.name, os.Getenv("JAMLAUNCH_ENV"), profile.Environment, cfg.Environment, DefaultEnvironment)
+	if apiUrl != "" {
+		name = CustomEnvironment
+	}
+	name = strings.ToLower(name)
+
+	env, ok := cfg.Environments[name]
+	if !ok {
+		env, ok = builtinEnvironments[name]
+	}
+	if !ok && name != CustomEnvironment {
+		return Environment{}, fmt.Errorf("unknown environment %q (available: %s)", name, strings.Join(environmentNames(cfg), ", "))
+	}
+
+	env.Name = name
+	if apiUrl != "" {
+		env.ApiBaseUrl = apiUrl
+	}
+	if appUrl != "" {
+		env.AppBaseUrl = appUrl
+	}
+
+	if env.ApiBaseUrl == "" {
+		return Environment{}, fmt.Errorf("environment %q has no API URL; set --api-url or JAMLAUNCH_API_URL", name)
+	}
+	if env.AppBaseUrl == "" {
+		env.AppBaseUrl = env.ApiBaseUrl
+	}
+
+	env.ApiBaseUrl = strings.TrimRight(env.ApiBaseUrl, "/")
+	env.AppBaseUrl = strings.TrimRight(env.AppBaseUrl, "/")
+
+	return env, nil
+}
+
+func environmentNames(cfg *Config) []string {
+	seen := map[string]bool{CustomEnvironment: true}
+	for name := range builtinEnvironments {
+		seen[name] = true
+	}
+	for name := range cfg.Environments {
+		seen[name] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// requestTimeout returns the per-request timeout: the --timeout flag when set,
+// then the "timeout" key of the config file, then jamlaunch.DefaultTimeout.
+func (cfg *Config) requestTimeout(flagTimeout time.Duration) (time.Duration, error) {
+	if flagTimeout > 0 {
+		return flagTimeout, nil
+	}
+	if cfg.Timeout == "" {
+		return jamlaunch.DefaultTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q in config: must be a positive duration such as \"30s\"", cfg.Timeout)
+	}
+	return timeout, nil
+}
+
+// tokenClockSkew returns the "clock_skew" config value, or the default.
+func (cfg *Config) tokenClockSkew() (time.Duration, error) {
+	if cfg.ClockSkew == "" {
+		return defaultClockSkew, nil
+	}
+
+	skew, err := time.ParseDuration(cfg.ClockSkew)
+	if err != nil || skew < 0 {
+		return 0, fmt.Errorf("invalid clock_skew %q in config: must be a duration such as \"60s\"", cfg.ClockSkew)
+	}
+	return skew, nil
+}
+
+func (rc *RetryConfig) policy() (jamlaunch.RetryPolicy, error) {
+	policy := jamlaunch.DefaultRetryPolicy
+	if rc == nil {
+		return policy, nil
+	}
+
+	if rc.MaxAttempts != nil {
+		policy.MaxAttempts = *rc.MaxAttempts
+	}
+
+	durations := []struct {
+		key   string
+		value string
+		dst   *time.Duration
+	}{
+		{"base_delay", rc.BaseDelay, &policy.BaseDelay},
+		{"max_delay", rc.MaxDelay, &policy.MaxDelay},
+		{"max_elapsed", rc.MaxElapsed, &policy.MaxElapsed},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid retry.%s in config: %w", d.key, err)
+		}
+		*d.dst = parsed
+	}
+
+	return policy, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
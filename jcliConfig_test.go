@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useConfigDir points the config directory at a fresh temporary directory
+// on every platform.
+func useConfigDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("APPDATA", dir)
+
+	configured, err := configDir()
+	if err != nil {
+		t.Fatalf("configDir: %v", err)
+	}
+	return configured
+}
+
+func TestLoadConfigWithoutFile(t *testing.T) {
+	useConfigDir(t)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if cfg.Profiles == nil || cfg.Environments == nil {
+		t.Errorf("loadConfig left maps nil: profiles %v, environments %v", cfg.Profiles, cfg.Environments)
+	}
+}
+
+func TestSaveTokenToEmptyConfigDir(t *testing.T) {
+	dir := useConfigDir(t)
+
+	if err := saveToken("dev-token"); err != nil {
+		t.Fatalf("saveToken: %v", err)
+	}
+	if err := savePlayerToken("player-token"); err != nil {
+		t.Fatalf("savePlayerToken: %v", err)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	p := cfg.profile(activeProfile)
+	if p.Token != "dev-token" || p.PlayerToken != "player-token" {
+		t.Errorf("profile %s = %+v, want both tokens saved", activeProfile, p)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatalf("stat config: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		t.Errorf("config permissions = %v, want private", perm)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chzyer/readline"
+)
+
+// historyLimit is how many prompt lines are kept in the history file.
+const historyLimit = 1000
+
+// errPromptInterrupted is returned by readLine when Ctrl-C is pressed while
+// the prompt is waiting for input.
+var errPromptInterrupted = errors.New("interrupted")
+
+// console is where the interactive prompt reads its lines from.
+type console interface {
+	// readLine shows prompt and returns the next line without its line
+	// ending. It returns io.EOF once the input is exhausted.
+	readLine(ctx context.Context, prompt string) (string, error)
+	// remember adds a command line to the history. Answers to questions
+	// are not remembered.
+	remember(line string)
+	close()
+}
+
+// newConsole returns a line editor with history and completion when stdin
+// and stdout are a terminal, and a plain line reader otherwise.
+func newConsole(st *cliState, interrupts <-chan os.Signal) console {
+	if isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		c, err := newTerminalConsole(st)
+		if err == nil {
+			return c
+		}
+		printError(fmt.Errorf("line editing unavailable: %w", err))
+	}
+	return &lineConsole{lines: readLines(), interrupts: interrupts}
+}
+
+// lineConsole reads stdin line by line, for input that is not a terminal.
+type lineConsole struct {
+	lines      <-chan inputLine
+	interrupts <-chan os.Signal
+}
+
+func (c *lineConsole) readLine(ctx context.Context, prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	select {
+	case <-ctx.Done():
+		fmt.Println()
+		return "", ctx.Err()
+	case <-c.interrupts:
+		fmt.Println()
+		return "", errPromptInterrupted
+	case input, ok := <-c.lines:
+		if !ok {
+			return "", io.EOF
+		}
+		if input.err != nil && input.text == "" {
+			return "", input.err
+		}
+		return strings.TrimRight(input.text, "\r\n"), nil
+	}
+}
+
+func (c *lineConsole) remember(line string) {}
+
+func (c *lineConsole) close() {}
+
+// terminalConsole edits lines in place: arrow keys and the usual Emacs
+// bindings move the cursor, Up and Down walk the history, Ctrl-R searches it
+// and Tab completes commands, flags, project names and session ids.
+type terminalConsole struct {
+	rl *readline.Instance
+}
+
+func newTerminalConsole(st *cliState) (*terminalConsole, error) {
+	cfg := &readline.Config{
+		AutoComplete:           &lineCompleter{st: st},
+		HistoryLimit:           historyLimit,
+		DisableAutoSaveHistory: true,
+		HistorySearchFold:      true,
+		InterruptPrompt:        "^C",
+	}
+
+	// The history can hold tokens passed to 'token inspect', so the file is
+	// created private before readline opens it.
+	if path, err := historyPath(); err == nil {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err == nil {
+			if f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600); err == nil {
+				f.Close()
+				cfg.HistoryFile = path
+			}
+		}
+	}
+
+	rl, err := readline.NewEx(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &terminalConsole{rl: rl}, nil
+}
+
+func (c *terminalConsole) readLine(ctx context.Context, prompt string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	c.rl.SetPrompt(prompt)
+	line, err := c.rl.Readline()
+	if errors.Is(err, readline.ErrInterrupt) {
+		return line, errPromptInterrupted
+	}
+	return line, err
+}
+
+func (c *terminalConsole) remember(line string) {
+	c.rl.SaveHistory(line)
+}
+
+func (c *terminalConsole) close() {
+	c.rl.Close()
+}
+
+func historyPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history"), nil
+}
@@ -1,75 +1,42 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
-)
-
-func fetch(apiUrl string, authToken string) (map[string]interface{}, error) {
-	req, err := http.NewRequest("GET", apiUrl, nil)
-	if err != nil {
-		return map[string]interface{}{}, fmt.Errorf("%w", err)
-	}
-
-	req.Header.Add("Authorization", "Bearer "+authToken)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return map[string]interface{}{}, fmt.Errorf("%w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatalf("\033[91mError reading response: %v\033[0m", err)
-		return map[string]interface{}{}, fmt.Errorf("error reading response: %w", err)
-	}
+	"os"
+	"time"
 
-	var data map[string]interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		log.Fatalf("\033[91mError unmarshaling JSON: %v\033[0m", err)
-		return map[string]interface{}{}, fmt.Errorf("error unmarshaling JSON: %w", err)
-	}
+	"github.com/jam-launch/jam-cli/jamlaunch"
+)
 
-	return data, nil
+// clientSettings holds the startup options applied to every API client.
+var clientSettings = struct {
+	timeout time.Duration
+	retry   jamlaunch.RetryPolicy
+	verbose bool
+}{
+	timeout: jamlaunch.DefaultTimeout,
+	retry:   jamlaunch.DefaultRetryPolicy,
 }
 
-func apiPost(apiUrl string, authToken string, body map[string]interface{}) (map[string]interface{}, error) {
-	jsonData, err := json.Marshal(body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", apiUrl, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+authToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatalf("\033[91mError reading response: %v\033[0m", err)
-		return map[string]interface{}{}, fmt.Errorf("\033[91mError reading response: %v\033[0m", err)
+func newClient(authToken string) *jamlaunch.Client {
+	client := jamlaunch.NewClient(activeEnv.ApiBaseUrl, authToken)
+	client.Timeout = clientSettings.timeout
+	client.Retry = clientSettings.retry
+	if clientSettings.verbose {
+		client.Logf = func(format string, args ...interface{}) {
+			fmt.Fprintf(os.Stderr, "\033[90m"+format+"\033[0m\n", args...)
+		}
 	}
+	return client
+}
 
-	var data map[string]interface{}
-	if err := json.Unmarshal(respBody, &data); err != nil {
-		return map[string]interface{}{}, fmt.Errorf("\033[91mError unmarshaling JSON: %v\033[0m", err)
+// fetch GETs an arbitrary API path and returns the decoded JSON, whatever its
+// shape. Typed endpoints should use the jamlaunch.Client methods instead.
+func fetch(ctx context.Context, path string, authToken string) (interface{}, error) {
+	var data interface{}
+	if err := newClient(authToken).Get(ctx, path, &data); err != nil {
+		return nil, err
 	}
-
 	return data, nil
 }
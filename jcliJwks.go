@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultClockSkew = 60 * time.Second
+	// Cached keys are refreshed after this long even if every kid matches.
+	jwksCacheTTL = 24 * time.Hour
+)
+
+// clockSkew is the leeway allowed when checking exp and nbf. It is set at
+// startup from the "clock_skew" config key.
+var clockSkew = defaultClockSkew
+
+// errNoKeys means the signature could not be checked offline, e.g. because
+// the environment publishes no JWKS or it could not be fetched.
+var errNoKeys = errors.New("no signing keys available")
+
+type signatureStatus string
+
+const (
+	signatureValid      signatureStatus = "valid"
+	signatureInvalid    signatureStatus = "invalid"
+	signatureUnverified signatureStatus = "unverified"
+)
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type jwksCache struct {
+	URL       string       `json:"url"`
+	FetchedAt time.Time    `json:"fetched_at"`
+	Keys      []jsonWebKey `json:"keys"`
+}
+
+func (e Environment) jwksUrl() string {
+	return firstNonEmpty(e.JwksUrl, e.apiUrl(".well-known/jwks.json"))
+}
+
+func jwksCachePath(env Environment) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "jwks-"+env.Name+".json"), nil
+}
+
+func loadJwksCache(env Environment) *jwksCache {
+	path, err := jwksCachePath(env)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var cache jwksCache
+	if err := json.Unmarshal(data, &cache); err != nil || cache.URL != env.jwksUrl() {
+		return nil
+	}
+	return &cache
+}
+
+func fetchJwks(ctx context.Context, env Environment) (*jwksCache, error) {
+	ctx, cancel := context.WithTimeout(ctx, clientSettings.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, env.jwksUrl(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", env.jwksUrl(), resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &jwksCache{URL: env.jwksUrl(), FetchedAt: time.Now().UTC()}
+	if err := json.Unmarshal(body, cache); err != nil {
+		return nil, fmt.Errorf("invalid JWKS document: %w", err)
+	}
+
+	if path, err := jwksCachePath(env); err == nil {
+		if data, err := json.MarshalIndent(cache, "", "  "); err == nil {
+			writeFileAtomic(path, data, 0600)
+		}
+	}
+
+	return cache, nil
+}
+
+// signingKey finds the key for kid, using the cached JWKS when it is fresh
+// and knows the kid, and refetching otherwise so rotated keys are picked up.
+// cached reports whether the key came from the cache without a refetch.
+func signingKey(ctx context.Context, env Environment, kid string) (key *jsonWebKey, cached bool, err error) {
+	cache := loadJwksCache(env)
+	if cache != nil && time.Since(cache.FetchedAt) < jwksCacheTTL {
+		if key := cache.find(kid); key != nil {
+			return key, true, nil
+		}
+	}
+
+	fresh, err := fetchJwks(ctx, env)
+	if err != nil {
+		if cache != nil {
+			if key := cache.find(kid); key != nil {
+				return key, true, nil
+			}
+		}
+		return nil, false, fmt.Errorf("%w: %s", errNoKeys, err)
+	}
+
+	if key := fresh.find(kid); key != nil {
+		return key, false, nil
+	}
+	return nil, false, fmt.Errorf("%w: kid %q is not in %s", errNoKeys, kid, env.jwksUrl())
+}
+
+func (c *jwksCache) find(kid string) *jsonWebKey {
+	for i := range c.Keys {
+		if c.Keys[i].Kid == kid || (kid == "" && len(c.Keys) == 1) {
+			return &c.Keys[i]
+		}
+	}
+	return nil
+}
+
+// checkSignature verifies the token's signature against the environment's
+// published keys. errNoKeys is returned when that is not possible offline.
+func checkSignature(ctx context.Context, tkn *TokenData) (signatureStatus, error) {
+	alg, _ := tkn.Header["alg"].(string)
+	kid, _ := tkn.Header["kid"].(string)
+
+	hash, ok := map[string]crypto.Hash{
+		"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+		"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+		"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+	}[alg]
+	if !ok {
+		return signatureUnverified, fmt.Errorf("%w: unsupported algorithm %q", errNoKeys, alg)
+	}
+
+	jwk, cached, err := signingKey(ctx, activeEnv, kid)
+	if err != nil {
+		return signatureUnverified, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(tkn.Signature)
+	if err != nil {
+		return signatureInvalid, fmt.Errorf("malformed signature: %w", err)
+	}
+
+	h := hash.New()
+	h.Write([]byte(tkn.SigningInput))
+	digest := h.Sum(nil)
+
+	status, err := verifySignature(jwk, alg, hash, digest, sig)
+	if status == signatureInvalid && cached {
+		// The key may have been replaced under the same kid; check once more
+		// against a freshly fetched JWKS before rejecting the token.
+		if fresh, fetchErr := fetchJwks(ctx, activeEnv); fetchErr == nil {
+			if jwk := fresh.find(kid); jwk != nil {
+				status, err = verifySignature(jwk, alg, hash, digest, sig)
+			}
+		}
+	}
+
+	return status, err
+}
+
+func verifySignature(jwk *jsonWebKey, alg string, hash crypto.Hash, digest []byte, sig []byte) (signatureStatus, error) {
+	switch alg[:2] {
+	case "RS", "PS":
+		pub, err := jwk.rsaKey()
+		if err != nil {
+			return signatureUnverified, fmt.Errorf("%w: %s", errNoKeys, err)
+		}
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+		} else {
+			err = rsa.VerifyPSS(pub, hash, digest, sig, nil)
+		}
+		if err != nil {
+			return signatureInvalid, fmt.Errorf("signature does not match key %q", jwk.Kid)
+		}
+	case "ES":
+		pub, err := jwk.ecdsaKey()
+		if err != nil {
+			return signatureUnverified, fmt.Errorf("%w: %s", errNoKeys, err)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return signatureInvalid, fmt.Errorf("signature has the wrong length for %s", alg)
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return signatureInvalid, fmt.Errorf("signature does not match key %q", jwk.Kid)
+		}
+	}
+
+	return signatureValid, nil
+}
+
+func (k *jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	if k.Kty != "RSA" {
+		return nil, fmt.Errorf("key %q is %s, not RSA", k.Kid, k.Kty)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("key %q has an invalid modulus", k.Kid)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("key %q has an invalid exponent", k.Kid)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+func (k *jsonWebKey) ecdsaKey() (*ecdsa.PublicKey, error) {
+	if k.Kty != "EC" {
+		return nil, fmt.Errorf("key %q is %s, not EC", k.Kid, k.Kty)
+	}
+
+	curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+	curve, ok := curves[k.Crv]
+	if !ok {
+		return nil, fmt.Errorf("key %q uses unsupported curve %q", k.Kid, k.Crv)
+	}
+
+	x, errX := base64.RawURLEncoding.DecodeString(k.X)
+	y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+	if errX != nil || errY != nil {
+		return nil, fmt.Errorf("key %q has invalid coordinates", k.Kid)
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
+
+// checkClaims validates exp, nbf, iss and aud. exp is required; iss and aud
+// are only compared when the environment configures an expected value.
+func checkClaims(claims map[string]interface{}, env Environment, now time.Time) error {
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("'exp' claim is missing or not a number")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return fmt.Errorf("Token Expired!")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("token is not valid before %s", time.Unix(int64(nbf), 0).Format(time.RFC1123))
+	}
+
+	if env.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != env.Issuer {
+			return fmt.Errorf("token issuer %q does not match %q", iss, env.Issuer)
+		}
+	}
+
+	if env.Audience != "" && !audienceContains(claims["aud"], env.Audience) {
+		return fmt.Errorf("token audience does not include %q", env.Audience)
+	}
+
+	return nil
+}
+
+func audienceContains(aud interface{}, want string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == want
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jam-launch/jam-cli/jamlaunch"
+)
+
+// jwksServer publishes a JWKS document that tests can replace to simulate a
+// key rotation, and counts how often it is fetched.
+type jwksServer struct {
+	mu      sync.Mutex
+	keys    []jsonWebKey
+	fetches atomic.Int32
+}
+
+func (s *jwksServer) publish(keys ...jsonWebKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.fetches.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.keys})
+}
+
+// useTestEnvironment makes activeEnv point at a JWKS server and an API whose
+// project listing answers with apiStatus, and gives it a fresh key cache.
+func useTestEnvironment(t *testing.T, apiStatus int) *jwksServer {
+	t.Helper()
+	useConfigDir(t)
+
+	keys := &jwksServer{}
+	jwks := httptest.NewServer(keys)
+	t.Cleanup(jwks.Close)
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(apiStatus)
+		w.Write([]byte(`{"projects":[]}`))
+	}))
+	t.Cleanup(api.Close)
+
+	saved := activeEnv
+	t.Cleanup(func() { activeEnv = saved })
+	activeEnv = Environment{Name: "test", ApiBaseUrl: api.URL, JwksUrl: jwks.URL}
+
+	return keys
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	return key
+}
+
+func publicJwk(kid string, key *rsa.PrivateKey) jsonWebKey {
+	return jsonWebKey{
+		Kid: kid,
+		Kty: "RSA",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// signToken builds an RS256 token signed with key. Any other alg is written
+// into the header with a meaningless signature.
+func signToken(t *testing.T, key *rsa.PrivateKey, alg string, kid string, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	sig := []byte("not a signature")
+	if alg == "RS256" {
+		digest := sha256.Sum256([]byte(input))
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("signing token: %v", err)
+		}
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func parsed(t *testing.T, token string) *TokenData {
+	t.Helper()
+	result := parseToken(token)
+	if result.Errored {
+		t.Fatalf("parseToken: %s", result.Error)
+	}
+	return result.Data
+}
+
+func TestCheckSignature(t *testing.T) {
+	key := generateKey(t)
+	other := generateKey(t)
+	token := signToken(t, key, "RS256", "k1", validClaims())
+
+	parts := strings.Split(token, ".")
+	forged, _ := json.Marshal(map[string]interface{}{"sub": "admin", "exp": time.Now().Add(time.Hour).Unix()})
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString(forged) + "." + parts[2]
+
+	tests := []struct {
+		name    string
+		token   string
+		want    signatureStatus
+		noKeys  bool
+		publish []jsonWebKey
+	}{
+		{"valid", token, signatureValid, false, []jsonWebKey{publicJwk("k1", key)}},
+		{"tampered claims", tampered, signatureInvalid, false, []jsonWebKey{publicJwk("k1", key)}},
+		{"signed by another key", token, signatureInvalid, false, []jsonWebKey{publicJwk("k1", other)}},
+		{"unknown kid", token, signatureUnverified, true, []jsonWebKey{publicJwk("k2", key)}},
+		{"unsupported alg", signToken(t, key, "HS256", "k1", validClaims()), signatureUnverified, true, []jsonWebKey{publicJwk("k1", key)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := useTestEnvironment(t, http.StatusOK)
+			keys.publish(tt.publish...)
+
+			status, err := checkSignature(context.Background(), parsed(t, tt.token))
+			if status != tt.want {
+				t.Fatalf("status = %s (%v), want %s", status, err, tt.want)
+			}
+			if errors.Is(err, errNoKeys) != tt.noKeys {
+				t.Errorf("err = %v, want errNoKeys %v", err, tt.noKeys)
+			}
+		})
+	}
+}
+
+func TestCheckSignatureAfterKeyRotation(t *testing.T) {
+	keys := useTestEnvironment(t, http.StatusOK)
+	oldKey, newKey := generateKey(t), generateKey(t)
+
+	keys.publish(publicJwk("k1", oldKey))
+	if status, err := checkSignature(context.Background(), parsed(t, signToken(t, oldKey, "RS256", "k1", validClaims()))); status != signatureValid {
+		t.Fatalf("before rotation: status = %s (%v)", status, err)
+	}
+
+	// The cache knows k1 only, so a token signed with the new key has to
+	// trigger a refetch.
+	keys.publish(publicJwk("k2", newKey))
+	if status, err := checkSignature(context.Background(), parsed(t, signToken(t, newKey, "RS256", "k2", validClaims()))); status != signatureValid {
+		t.Fatalf("new kid: status = %s (%v)", status, err)
+	}
+	if got := keys.fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+
+	// A key replaced under a kid that is still cached is picked up when the
+	// cached key rejects the signature.
+	keys.publish(publicJwk("k2", oldKey))
+	if status, err := checkSignature(context.Background(), parsed(t, signToken(t, oldKey, "RS256", "k2", validClaims()))); status != signatureValid {
+		t.Fatalf("replaced key: status = %s (%v)", status, err)
+	}
+	if got := keys.fetches.Load(); got != 3 {
+		t.Errorf("JWKS fetched %d times, want 3", got)
+	}
+}
+
+// TestCheckTokenFallsBackToAPI also checks that nothing is written to stdout,
+// which is reserved for a command's own, possibly machine-readable, output.
+func TestCheckTokenFallsBackToAPI(t *testing.T) {
+	saved := clientSettings.retry
+	t.Cleanup(func() { clientSettings.retry = saved })
+	clientSettings.retry = jamlaunch.RetryPolicy{MaxAttempts: 1}
+
+	tests := []struct {
+		name      string
+		apiStatus int
+		want      bool
+	}{
+		{"accepted", http.StatusOK, true},
+		{"rejected", http.StatusUnauthorized, false},
+		{"forbidden but accepted", http.StatusForbidden, true},
+		{"api failing", http.StatusInternalServerError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := useTestEnvironment(t, tt.apiStatus)
+			key := generateKey(t)
+			keys.publish(publicJwk("k1", key))
+
+			token := signToken(t, key, "HS256", "k1", validClaims())
+			var got bool
+			out, _ := captureStdout(t, func() error {
+				got = checkToken(context.Background(), token)
+				return nil
+			})
+			if got != tt.want {
+				t.Errorf("checkToken = %v, want %v", got, tt.want)
+			}
+			if out != "" {
+				t.Errorf("checkToken wrote to stdout: %q", out)
+			}
+		})
+	}
+
+	t.Run("malformed", func(t *testing.T) {
+		out, _ := captureStdout(t, func() error {
+			checkToken(context.Background(), "not-a-jwt")
+			return nil
+		})
+		if out != "" {
+			t.Errorf("checkToken wrote to stdout: %q", out)
+		}
+	})
+}
+
+func TestCheckClaims(t *testing.T) {
+	prod := builtinEnvironments["prod"]
+	checked := Environment{Issuer: "https://issuer.example", Audience: "dev-client", PlayerAudience: "player-client"}
+	now := time.Now()
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"exp": float64(now.Add(time.Hour).Unix())}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		env     Environment
+		claims  map[string]interface{}
+		wantErr bool
+	}{
+		{"developer token", checked, claims(map[string]interface{}{"iss": checked.Issuer, "aud": "dev-client"}), false},
+		{"audience list", checked, claims(map[string]interface{}{"iss": checked.Issuer, "aud": []interface{}{"other", "dev-client"}}), false},
+		{"player token", checked.forPlayer(), claims(map[string]interface{}{"iss": checked.Issuer, "aud": "player-client"}), false},
+		{"player token as developer", checked, claims(map[string]interface{}{"iss": checked.Issuer, "aud": "player-client"}), true},
+		{"other issuer", checked, claims(map[string]interface{}{"iss": "https://other.example", "aud": "dev-client"}), true},
+		{"missing issuer", checked, claims(map[string]interface{}{"aud": "dev-client"}), true},
+		{"missing audience", checked, claims(map[string]interface{}{"iss": checked.Issuer}), true},
+		{"built-in environment without iss or aud", prod, claims(nil), false},
+		{"built-in player environment without iss or aud", prod.forPlayer(), claims(nil), false},
+		{"unchecked environment", Environment{}, claims(nil), false},
+		{"expired", Environment{}, map[string]interface{}{"exp": float64(now.Add(-time.Hour).Unix())}, true},
+		{"within clock skew", Environment{}, map[string]interface{}{"exp": float64(now.Add(-time.Second).Unix())}, false},
+		{"not yet valid", Environment{}, claims(map[string]interface{}{"nbf": float64(now.Add(time.Hour).Unix())}), true},
+		{"missing exp", Environment{}, map[string]interface{}{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkClaims(tt.claims, tt.env, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkClaims = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
+
+	"github.com/jam-launch/jam-cli/jamlaunch"
 )
 
 type TokenData struct {
 	Header    map[string]interface{}
 	Claims    map[string]interface{}
 	Signature string
+	// SigningInput is the "header.claims" part the signature covers.
+	SigningInput string
 }
 
 type TokenParseResult struct {
@@ -21,67 +28,214 @@ type TokenParseResult struct {
 	Error   string
 }
 
-func loadToken() (bool, string) {
-	file, err := os.Open("userConfig.json")
+// tokenEnvVar names the variable that supplies a token for one run without
+// storing it. It takes precedence over the active profile's token.
+const tokenEnvVar = "JAMLAUNCH_TOKEN"
+
+var errLoginRequired = errors.New("login required")
+
+// noTokenError explains how to provide a token when none is usable and the
+// device login cannot run.
+func noTokenError() error {
+	return fmt.Errorf("%w: no valid token; set %s or run 'jam-cli login --with-token'", errLoginRequired, tokenEnvVar)
+}
+
+// canLogin reports whether the device login may be started. It is never
+// started in CI, or when the token comes from JAMLAUNCH_TOKEN since a stored
+// token would not replace it.
+func canLogin() bool {
+	return !ciMode && os.Getenv(tokenEnvVar) == ""
+}
 
+// canPrompt reports whether questions can be asked on stdin.
+func canPrompt() bool {
+	return canLogin() && isTerminal(os.Stdin)
+}
+
+// loginWithToken validates a token read from r and stores it in the active
+// profile, as its player token when asPlayer is set. It never prompts.
+func loginWithToken(ctx context.Context, r io.Reader, asPlayer bool) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, 64<<10))
 	if err != nil {
+		return "", fmt.Errorf("failed to read token from stdin: %w", err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("%w: no token on stdin", errLoginRequired)
+	}
+
+	if asPlayer {
+		if err := checkPlayerToken(ctx, token); err != nil {
+			return "", fmt.Errorf("%w: the token on stdin is invalid: %s", errLoginRequired, err)
+		}
+		if err := savePlayerToken(token); err != nil {
+			return "", err
+		}
+		fmt.Printf("\033[92mPlayer token saved to profile %s.\033[0m\n", activeProfile)
+		return token, nil
+	}
+
+	if !checkToken(ctx, token) {
+		return "", fmt.Errorf("%w: the token on stdin is invalid or expired", errLoginRequired)
+	}
+
+	if err := saveToken(token); err != nil {
+		return "", err
+	}
+
+	fmt.Printf("\033[92mToken saved to profile %s.\033[0m\n", activeProfile)
+	if os.Getenv(tokenEnvVar) != "" {
+		fmt.Printf("\033[93mNote: %s is set and takes precedence over the saved token.\033[0m\n", tokenEnvVar)
+	}
+
+	return token, nil
+}
+
+func envTrue(name string) bool {
+	switch strings.ToLower(os.Getenv(name)) {
+	case "", "0", "false", "no":
+		return false
+	}
+	return true
+}
+
+// legacyTokenFile is where tokens were saved before they moved into the
+// config directory. It is relative to the working directory.
+const legacyTokenFile = "userConfig.json"
+
+func loadToken(ctx context.Context) (bool, string) {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n\033[91mError: %s\033[0m\n", err)
+		return false, ""
+	}
+
+	if envToken := os.Getenv(tokenEnvVar); envToken != "" {
+		if !checkToken(ctx, envToken) {
+			fmt.Fprintf(os.Stderr, "\033[91mThe token in %s is invalid or expired.\033[0m\n", tokenEnvVar)
+			return false, ""
+		}
+		return true, envToken
+	}
+
+	authToken := cfg.profile(activeProfile).Token
+	if authToken == "" && activeProfile == DefaultProfile {
+		authToken = migrateLegacyToken()
+	}
+
+	if !checkToken(ctx, authToken) {
 		return false, ""
 	}
+
+	return true, authToken
+}
+
+// migrateLegacyToken moves a token from ./userConfig.json into the config
+// store and deletes the old file. It returns the token, or "" when there is
+// nothing to migrate.
+func migrateLegacyToken() string {
+	file, err := os.Open(legacyTokenFile)
+	if err != nil {
+		return ""
+	}
 	defer file.Close()
 
 	var data map[string]string
 	decoder := json.NewDecoder(file)
 	if err := decoder.Decode(&data); err != nil {
-		return false, ""
+		return ""
 	}
 
 	authToken, ok := data["authToken"]
 	if !ok {
-		fmt.Printf("\n\033[91mError: missing auth token\033[0m\n")
-		return false, ""
+		fmt.Fprintf(os.Stderr, "\n\033[91mError: missing auth token\033[0m\n")
+		return ""
 	}
 
-	if !checkToken(authToken) {
-		return false, ""
+	if err := saveToken(authToken); err != nil {
+		fmt.Fprintf(os.Stderr, "\n\033[91mError: unable to migrate %s: %s\033[0m\n", legacyTokenFile, err)
+		return authToken
 	}
 
-	return true, authToken
+	file.Close()
+	if err := os.Remove(legacyTokenFile); err != nil {
+		fmt.Fprintf(os.Stderr, "\n\033[93mWarning: token copied to the config directory but %s could not be removed: %s\033[0m\n", legacyTokenFile, err)
+	} else if path, err := configPath(); err == nil {
+		fmt.Fprintf(os.Stderr, "\n\033[93mMoved token from %s to %s\033[0m\n", legacyTokenFile, path)
+	}
+
+	return authToken
 }
 
-func checkToken(token string) bool {
+func checkToken(ctx context.Context, token string) bool {
 	if token == "" {
 		return false
 	}
 
 	result := parseToken(token)
 	if result.Errored {
-		fmt.Printf("\n\033[91mError: %s\033[0m\n", result.Error)
+		fmt.Fprintf(os.Stderr, "\n\033[91mError: %s\033[0m\n", result.Error)
 		return false
 	}
 
-	expiration, ok := result.Data.Claims["exp"].(float64)
-	if !ok {
-		fmt.Printf("\n\033[91mError: 'exp' claim is missing or not a float64\033[0m\n")
+	if err := checkClaims(result.Data.Claims, activeEnv, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "\n\033[91mError: %s\033[0m\n", err)
 		return false
 	}
 
-	expTime := time.Unix(int64(expiration), 0)
-
-	if time.Now().After(expTime) {
-		fmt.Printf("\n\033[91mError: Token Expired!\033[0m\n")
+	status, err := checkSignature(ctx, result.Data)
+	if status == signatureValid {
+		return true
+	}
+	if status == signatureInvalid {
+		fmt.Fprintf(os.Stderr, "\n\033[91mError: Token Invalid! %s\033[0m\n", err)
 		return false
 	}
 
-	verifyResult := verifyToken(token)
+	// The signature could not be checked offline; ask the API instead.
+	if clientSettings.verbose {
+		fmt.Fprintf(os.Stderr, "\n\033[90mOffline verification unavailable (%s); checking with the API\033[0m\n", err)
+	}
+	verifyResult := verifyToken(ctx, token)
 
 	if !verifyResult {
-		fmt.Printf("\n\033[91mError: Token Invalid!\033[0m\n")
+		fmt.Fprintf(os.Stderr, "\n\033[91mError: Token Invalid!\033[0m\n")
 		return false
 	}
 
 	return true
 }
 
+// checkPlayerToken validates a player token offline. Unlike checkToken it
+// does not fall back to the API, since players cannot list projects.
+func checkPlayerToken(ctx context.Context, token string) error {
+	result := parseToken(token)
+	if result.Errored {
+		return errors.New(result.Error)
+	}
+	if err := checkClaims(result.Data.Claims, activeEnv.forPlayer(), time.Now()); err != nil {
+		return err
+	}
+	if status, err := checkSignature(ctx, result.Data); status == signatureInvalid {
+		return err
+	}
+	return nil
+}
+
+// playerToken returns the active profile's player token, or an error
+// explaining how to get one.
+func (st *cliState) playerToken(ctx context.Context) (string, error) {
+	token := st.config.profile(activeProfile).PlayerToken
+	if token == "" {
+		return "", fmt.Errorf("%w: profile %s has no player login; run 'login --as-player' first", errLoginRequired, activeProfile)
+	}
+	if err := checkPlayerToken(ctx, token); err != nil {
+		return "", fmt.Errorf("%w: the player token of profile %s is not usable (%s); run 'login --as-player'", errLoginRequired, activeProfile, err)
+	}
+	return token, nil
+}
+
 func parseToken(token string) TokenParseResult {
 	var result TokenParseResult
 	parts := strings.Split(token, ".")
@@ -121,12 +275,18 @@ func parseToken(token string) TokenParseResult {
 
 	// Parse Signature
 	sig, err := decodeBase64URL(parts[2])
-	if err != nil || len(sig) == 0 {
+	if err != nil {
 		result.Errored = true
 		result.Error = "Failed to decode JWT signature: " + err.Error()
 		return result
 	}
+	if len(sig) == 0 {
+		result.Errored = true
+		result.Error = "JWT signature is empty"
+		return result
+	}
 	tkn.Signature = parts[2]
+	tkn.SigningInput = parts[0] + "." + parts[1]
 
 	result.Data = tkn
 	return result
@@ -140,19 +300,21 @@ func decodeBase64URL(data string) (string, error) {
 	return string(decoded), nil
 }
 
-func verifyToken(authToken string) bool {
-	var apiUrl = "https://api.jamlaunch.com/projects"
-
-	data, success := fetch(apiUrl, authToken)
-
-	if success == nil {
-		if _, exists := data["projects"]; exists {
-			return true
-		} else {
-			return false
-		}
-	} else {
-		fmt.Printf("\033[91m%s\033[0m\n", success)
+func verifyToken(ctx context.Context, authToken string) bool {
+	_, err := newClient(authToken).ListProjects(ctx)
+	if errors.Is(err, jamlaunch.ErrUnauthorized) {
+		fmt.Fprintf(os.Stderr, "\033[91m%s\033[0m\n", err)
 		return false
 	}
+	if errors.Is(err, jamlaunch.ErrForbidden) {
+		// The token was accepted; it just may not list projects.
+		return true
+	}
+	if err != nil {
+		// The token may still be fine; don't force a new login because the
+		// API is unreachable or failing.
+		fmt.Fprintf(os.Stderr, "\n\033[93mWarning: unable to verify token: %s\033[0m\n", err)
+	}
+
+	return true
 }
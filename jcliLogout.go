@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/jam-launch/jam-cli/jamlaunch"
+)
+
+// logout revokes and removes the tokens, developer and player, of the named
+// profile, or of every profile when all is set. Tokens are removed locally
+// even when the server cannot revoke them.
+func logout(ctx context.Context, st *cliState, name string, all bool) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	targets := []string{firstNonEmpty(name, activeProfile)}
+	if all {
+		targets = []string{}
+		for n, p := range cfg.Profiles {
+			if p != nil && (p.Token != "" || p.PlayerToken != "") {
+				targets = append(targets, n)
+			}
+		}
+		sort.Strings(targets)
+	} else if _, ok := cfg.Profiles[targets[0]]; !ok && name != "" {
+		return fmt.Errorf("profile %q does not exist", name)
+	}
+
+	removed := []string{}
+	for _, n := range targets {
+		p := cfg.profile(n)
+		if p.Token == "" && p.PlayerToken == "" {
+			fmt.Printf("Profile %s is not logged in.\n", n)
+			continue
+		}
+
+		if p.Token != "" {
+			fmt.Printf("Profile %s: %s\n", n, revokeToken(ctx, cfg, n, p.Token))
+		}
+		if p.PlayerToken != "" {
+			fmt.Printf("Profile %s (player): %s\n", n, revokeToken(ctx, cfg, n, p.PlayerToken))
+		}
+		removed = append(removed, n)
+	}
+
+	if len(removed) > 0 {
+		err = updateConfig(func(cfg *Config) error {
+			for _, n := range removed {
+				if p, ok := cfg.Profiles[n]; ok && p != nil {
+					p.Token = ""
+					p.PlayerToken = ""
+				}
+			}
+			st.config = cfg
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to remove tokens: %w", err)
+		}
+	}
+
+	for _, n := range removed {
+		if n == activeProfile {
+			st.token = ""
+		}
+		if n == DefaultProfile {
+			removeLegacyToken()
+		}
+		fmt.Printf("\033[92mLogged out of profile %s.\033[0m\n", n)
+	}
+
+	if len(removed) == 0 && all {
+		fmt.Println("No profiles are logged in.")
+	}
+
+	return nil
+}
+
+// revokeToken asks the profile's environment to revoke token and describes
+// the outcome. Failures are reported rather than returned so the token is
+// still removed locally.
+func revokeToken(ctx context.Context, cfg *Config, name string, token string) string {
+	env, err := selectEnvironment(cfg, cfg.profile(name))
+	if err != nil {
+		return fmt.Sprintf("\033[93mnot revoked: %s\033[0m", err)
+	}
+
+	client := newClient(token)
+	client.BaseURL = env.ApiBaseUrl
+
+	err = client.RevokeToken(ctx)
+
+	var apiErr *jamlaunch.APIError
+	switch {
+	case err == nil:
+		return "token revoked"
+	case errors.Is(err, jamlaunch.ErrUnauthorized):
+		return "token was already invalid"
+	case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusMethodNotAllowed || apiErr.StatusCode == http.StatusNotImplemented):
+		return fmt.Sprintf("%s does not support revocation; the token stays valid until it expires", env.Name)
+	}
+	return fmt.Sprintf("\033[93mnot revoked: %s\033[0m", err)
+}
+
+// removeLegacyToken deletes ./userConfig.json so loadToken does not migrate
+// the token straight back into the default profile.
+func removeLegacyToken() {
+	if err := os.Remove(legacyTokenFile); err == nil {
+		fmt.Printf("Removed %s.\n", legacyTokenFile)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRevokeToken(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{http.StatusOK, "token revoked"},
+		{http.StatusUnauthorized, "token was already invalid"},
+		{http.StatusForbidden, "not revoked"},
+		{http.StatusNotFound, "does not support revocation"},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{}`))
+			}))
+			t.Cleanup(api.Close)
+
+			cfg := &Config{
+				Environment:  "test",
+				Environments: map[string]Environment{"test": {ApiBaseUrl: api.URL}},
+				Profiles:     map[string]*Profile{},
+			}
+			if got := revokeToken(context.Background(), cfg, "default", "token"); !strings.Contains(got, tt.want) {
+				t.Errorf("revokeToken = %q, want it to mention %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jam-launch/jam-cli/jamlaunch"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+	formatCSV   = "csv"
+	formatTSV   = "tsv"
+)
+
+var outputFormats = []string{formatTable, formatJSON, formatYAML, formatCSV, formatTSV}
+
+// The record types below are the machine-readable shape of each listing.
+// Their field names are part of the CLI's interface: add fields freely, but
+// do not rename or remove them.
+
+type projectRecord struct {
+	ID        string          `json:"id" yaml:"id"`
+	Name      string          `json:"name" yaml:"name"`
+	CreatedAt string          `json:"created_at,omitempty" yaml:"created_at,omitempty"`
+	Active    *bool           `json:"active,omitempty" yaml:"active,omitempty"`
+	Members   []memberRecord  `json:"members,omitempty" yaml:"members,omitempty"`
+	Releases  []releaseRecord `json:"releases,omitempty" yaml:"releases,omitempty"`
+}
+
+type memberRecord struct {
+	Username string `json:"username" yaml:"username"`
+	Level    string `json:"level" yaml:"level"`
+}
+
+type releaseRecord struct {
+	ID          string `json:"id" yaml:"id"`
+	CreatedAt   string `json:"created_at" yaml:"created_at"`
+	IsDefault   bool   `json:"is_default" yaml:"is_default"`
+	Public      bool   `json:"public" yaml:"public"`
+	NetworkMode string `json:"network_mode" yaml:"network_mode"`
+	ServerBuild bool   `json:"server_build" yaml:"server_build"`
+	AllowGuests bool   `json:"allow_guests" yaml:"allow_guests"`
+}
+
+type sessionRecord struct {
+	ID        string         `json:"id" yaml:"id"`
+	Address   string         `json:"address" yaml:"address"`
+	CreatedAt string         `json:"created_at,omitempty" yaml:"created_at,omitempty"`
+	JoinCode  string         `json:"join_code,omitempty" yaml:"join_code,omitempty"`
+	Region    string         `json:"region,omitempty" yaml:"region,omitempty"`
+	State     string         `json:"state" yaml:"state"`
+	Players   []playerRecord `json:"players,omitempty" yaml:"players,omitempty"`
+}
+
+type playerRecord struct {
+	Username string `json:"username" yaml:"username"`
+	Host     bool   `json:"host" yaml:"host"`
+}
+
+// outputFormat picks the format for a listing: the --output flag, then the
+// "output" key of the config file, then table.
+func (st *cliState) outputFormat(args *commandArgs) (string, error) {
+	format := args.flag("output")
+	if format == "" && st.config != nil {
+		format = st.config.Output
+	}
+	if format == "" {
+		return formatTable, nil
+	}
+
+	format = strings.ToLower(format)
+	for _, f := range outputFormats {
+		if f == format {
+			return format, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown output format %q (available: %s)", format, strings.Join(outputFormats, ", "))
+}
+
+// emit writes a listing in one of the machine-readable formats. JSON and YAML
+// encode record as a whole, while CSV and TSV write columns as the header
+// followed by rows.
+func emit(format string, record interface{}, columns []string, rows [][]string) error {
+	switch format {
+	case formatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(record)
+	case formatYAML:
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case formatCSV, formatTSV:
+		writer := csv.NewWriter(os.Stdout)
+		if format == formatTSV {
+			writer.Comma = '\t'
+		}
+		if err := writer.Write(columns); err != nil {
+			return err
+		}
+		if err := writer.WriteAll(rows); err != nil {
+			return err
+		}
+		return writer.Error()
+	}
+
+	return fmt.Errorf("unsupported output format %q", format)
+}
+
+func newProjectRecord(project *jamlaunch.Project) projectRecord {
+	record := projectRecord{
+		ID:        project.ID,
+		Name:      project.Name,
+		CreatedAt: formatTime(project.CreatedAt),
+		Active:    &project.Active,
+		Members:   []memberRecord{},
+		Releases:  []releaseRecord{},
+	}
+
+	for _, member := range project.Members {
+		record.Members = append(record.Members, memberRecord{Username: member.Username, Level: member.Level})
+	}
+
+	for _, release := range project.Releases {
+		record.Releases = append(record.Releases, releaseRecord{
+			ID:          release.ID,
+			CreatedAt:   formatTime(release.CreatedAt),
+			IsDefault:   release.IsDefault,
+			Public:      release.Public,
+			NetworkMode: release.NetworkMode,
+			ServerBuild: release.ServerBuild,
+			AllowGuests: release.AllowGuests,
+		})
+	}
+
+	return record
+}
+
+func newSessionRecord(session *jamlaunch.Session) sessionRecord {
+	record := sessionRecord{
+		ID:       session.ID,
+		Address:  session.Address,
+		JoinCode: session.JoinCode,
+		Region:   session.Region,
+		State:    session.State,
+		Players:  []playerRecord{},
+	}
+
+	for _, player := range session.Players {
+		record.Players = append(record.Players, playerRecord{Username: player.Username, Host: player.Host})
+	}
+
+	return record
+}
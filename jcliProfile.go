@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/table"
+)
+
+const DefaultProfile = "default"
+
+// Profile is a named account: its token and the environment and project its
+// commands use by default.
+type Profile struct {
+	Token string `json:"token,omitempty"`
+	// PlayerToken comes from 'login --as-player' and is used by commands run
+	// with --as-player.
+	PlayerToken string `json:"player_token,omitempty"`
+	// Scope is requested when the developer token is renewed. It is set by
+	// 'login --scope'.
+	Scope       string `json:"scope,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	// Project is the id of the current project chosen with 'use', and
+	// ProjectName its name for the prompt. When ProjectName is empty,
+	// Project is a name or id as typed, e.g. with 'profile use --project',
+	// and is resolved each time it is used.
+	Project     string `json:"project,omitempty"`
+	ProjectName string `json:"project_name,omitempty"`
+}
+
+// activeProfile names the profile whose token is loaded and saved. It is set
+// at startup from --profile, JAMLAUNCH_PROFILE or the config file.
+var activeProfile = DefaultProfile
+
+type profileRecord struct {
+	Name        string `json:"name" yaml:"name"`
+	Active      bool   `json:"active" yaml:"active"`
+	Environment string `json:"environment" yaml:"environment"`
+	Project     string `json:"project" yaml:"project"`
+	LoggedIn    bool   `json:"logged_in" yaml:"logged_in"`
+	Player      bool   `json:"player_logged_in" yaml:"player_logged_in"`
+	ProjectID   string `json:"project_id,omitempty" yaml:"project_id,omitempty"`
+}
+
+func (cfg *Config) profile(name string) *Profile {
+	if p, ok := cfg.Profiles[name]; ok && p != nil {
+		return p
+	}
+	return &Profile{}
+}
+
+// currentProject returns the profile's current project, if any.
+func (p *Profile) currentProject() (projectRef, bool) {
+	switch {
+	case p.Project == "":
+		return projectRef{}, false
+	case p.ProjectName == "":
+		return projectRef{query: p.Project}, true
+	}
+	return projectRef{id: p.Project, name: p.ProjectName}, true
+}
+
+// selectProfile picks the profile named by --profile, then JAMLAUNCH_PROFILE,
+// then the config file, and makes it and its environment active.
+func selectProfile(cfg *Config, flagProfile string) error {
+	name := firstNonEmpty(flagProfile, os.Getenv("JAMLAUNCH_PROFILE"), cfg.Profile, DefaultProfile)
+	return activateProfile(cfg, name)
+}
+
+func activateProfile(cfg *Config, name string) error {
+	env, err := selectEnvironment(cfg, cfg.profile(name))
+	if err != nil {
+		return fmt.Errorf("profile %s: %w", name, err)
+	}
+
+	activeProfile = name
+	activeEnv = env
+	return nil
+}
+
+// promptPrefix shows the active profile, when there is more than one, and
+// the current project in front of the prompt, e.g. "[studio] MyGame".
+func promptPrefix(cfg *Config) string {
+	parts := []string{}
+	if activeProfile != DefaultProfile || len(cfg.Profiles) > 1 {
+		parts = append(parts, "["+activeProfile+"]")
+	}
+	if project, ok := cfg.profile(activeProfile).currentProject(); ok {
+		parts = append(parts, project.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// profileNames lists the configured profiles and the active one, which may
+// not have been saved yet.
+func profileNames(cfg *Config) []string {
+	names := []string{}
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	if _, ok := cfg.Profiles[activeProfile]; !ok {
+		names = append(names, activeProfile)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func profileList(st *cliState, format string) error {
+	names := profileNames(st.config)
+
+	records := []profileRecord{}
+	rows := [][]string{}
+	for _, name := range names {
+		p := st.config.profile(name)
+		project, _ := p.currentProject()
+		record := profileRecord{
+			Name:        name,
+			Active:      name == activeProfile,
+			Environment: firstNonEmpty(p.Environment, st.config.Environment, DefaultEnvironment),
+			Project:     project.String(),
+			LoggedIn:    p.Token != "",
+			Player:      p.PlayerToken != "",
+			ProjectID:   project.id,
+		}
+		records = append(records, record)
+		rows = append(rows, []string{record.Name, fmt.Sprint(record.Active), record.Environment, record.Project, fmt.Sprint(record.LoggedIn), fmt.Sprint(record.Player), record.ProjectID})
+	}
+
+	if format != formatTable {
+		return emit(format, records, []string{"name", "active", "environment", "project", "logged_in", "player_logged_in", "project_id"}, rows)
+	}
+
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"", "Profile", "Environment", "Project", "Logged In", "Player"})
+	t.SetTitle("Profiles")
+	t.SetStyle(table.StyleColoredDark)
+
+	for _, record := range records {
+		marker := ""
+		if record.Active {
+			marker = "*"
+		}
+		t.AppendRow(table.Row{marker, record.Name, record.Environment, record.Project, record.LoggedIn, record.Player})
+	}
+
+	fmt.Println(t.Render())
+
+	return nil
+}
+
+// profileUse makes name the active profile, creating it if needed, and
+// optionally updates its environment and default project.
+func profileUse(st *cliState, name string, env string, project string) error {
+	created := false
+
+	err := updateConfig(func(cfg *Config) error {
+		p, ok := cfg.Profiles[name]
+		if !ok || p == nil {
+			p = &Profile{}
+			cfg.Profiles[name] = p
+			created = true
+		}
+		if env != "" {
+			p.Environment = strings.ToLower(env)
+		}
+		if project != "" {
+			p.Project = project
+			p.ProjectName = ""
+		}
+		cfg.Profile = name
+
+		if err := activateProfile(cfg, name); err != nil {
+			return err
+		}
+
+		st.config = cfg
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	st.token = st.config.profile(name).Token
+
+	if created {
+		fmt.Printf("\033[92mCreated profile %s.\033[0m\n", name)
+	}
+	fmt.Printf("\033[92mNow using profile %s\033[0m (%s, %s)\n", name, activeEnv.Name, activeEnv.ApiBaseUrl)
+	if st.token == "" {
+		fmt.Println("This profile is not logged in yet; run 'login' to authenticate.")
+	}
+
+	return nil
+}
+
+func profileRemove(st *cliState, name string) error {
+	err := updateConfig(func(cfg *Config) error {
+		if _, ok := cfg.Profiles[name]; !ok {
+			return fmt.Errorf("profile %q does not exist", name)
+		}
+		delete(cfg.Profiles, name)
+
+		if cfg.Profile == name {
+			cfg.Profile = ""
+		}
+		st.config = cfg
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\033[92mRemoved profile %s.\033[0m\n", name)
+
+	if name == activeProfile {
+		st.token = ""
+		if err := activateProfile(st.config, firstNonEmpty(st.config.Profile, DefaultProfile)); err != nil {
+			return err
+		}
+		fmt.Printf("Now using profile %s.\n", activeProfile)
+	}
+
+	return nil
+}
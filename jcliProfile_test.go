@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProfileCurrentProject(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		want    projectRef
+		ok      bool
+		prompt  string
+	}{
+		{"none", Profile{}, projectRef{}, false, ""},
+		{"chosen with use", Profile{Project: "p2", ProjectName: "MyGame"}, projectRef{id: "p2", name: "MyGame"}, true, "MyGame"},
+		{"typed name", Profile{Project: "mygame"}, projectRef{query: "mygame"}, true, "mygame"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.profile.currentProject()
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("currentProject = %+v, %v; want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+
+			cfg := &Config{Profiles: map[string]*Profile{activeProfile: &tt.profile}}
+			if prefix := promptPrefix(cfg); prefix != tt.prompt {
+				t.Errorf("promptPrefix = %q, want %q", prefix, tt.prompt)
+			}
+		})
+	}
+}
+
+// TestStoredProjectSkipsProjectList checks that a project chosen with 'use'
+// is sent to the API by id, while a typed name is first looked up.
+func TestStoredProjectSkipsProjectList(t *testing.T) {
+	var mu sync.Mutex
+	requests := []string{}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.Path)
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/projects":
+			w.Write([]byte(`{"projects":[{"id":"p2","project_name":"MyGame"},{"id":"p3","project_name":"Renamed"}]}`))
+		case "/projects/p2":
+			w.Write([]byte(`{"id":"p2","project_name":"MyGame","created_at":"2026-01-02T03:04:05Z","active":true,"members":[],"releases":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(api.Close)
+
+	saved := activeEnv
+	t.Cleanup(func() { activeEnv = saved })
+	activeEnv = Environment{Name: "test", ApiBaseUrl: api.URL}
+
+	tests := []struct {
+		name string
+		ref  projectRef
+		want []string
+	}{
+		{"stored id", projectRef{id: "p2", name: "MyGame"}, []string{"/projects/p2"}},
+		{"typed name", projectRef{query: "mygame"}, []string{"/projects", "/projects/p2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			requests = requests[:0]
+			mu.Unlock()
+
+			st := &cliState{token: "token"}
+			_, err := captureStdout(t, func() error {
+				return projectMembers(context.Background(), st, tt.ref, formatJSON)
+			})
+			if err != nil {
+				t.Fatalf("projectMembers: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if got := strings.Join(requests, " "); got != strings.Join(tt.want, " ") {
+				t.Errorf("requests = %s, want %s", got, strings.Join(tt.want, " "))
+			}
+		})
+	}
+}
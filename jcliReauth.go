@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jam-launch/jam-cli/jamlaunch"
+)
+
+// expiryWarning is how long before the token expires commands start warning
+// about it.
+const expiryWarning = 5 * time.Minute
+
+var errTokenExpired = errors.New("token expired")
+
+func tokenExpiry(token string) (time.Time, bool) {
+	result := parseToken(token)
+	if result.Errored {
+		return time.Time{}, false
+	}
+	return claimTime(result.Data.Claims, "exp")
+}
+
+// checkExpiry returns errTokenExpired once the token has expired, and warns
+// once per token when it is about to.
+func (st *cliState) checkExpiry() error {
+	exp, ok := tokenExpiry(st.token)
+	if !ok {
+		return nil
+	}
+
+	left := time.Until(exp)
+	if left <= 0 {
+		return fmt.Errorf("%w at %s", errTokenExpired, exp.Local().Format(time.RFC1123))
+	}
+	if left <= expiryWarning && st.warnedToken != st.token {
+		st.warnedToken = st.token
+		fmt.Fprintf(os.Stderr, "\033[93mWarning: your token expires in %s; run 'login' to renew it.\033[0m\n", left.Round(time.Second))
+	}
+	return nil
+}
+
+// tokenRejected reports whether the API refused the token itself, as opposed
+// to refusing access to a particular resource.
+func tokenRejected(err error) bool {
+	var apiErr *jamlaunch.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized
+}
+
+// reauthenticate offers to run the device flow again and reports whether a
+// new token was obtained. It never prompts when nobody can answer.
+func (st *cliState) reauthenticate(ctx context.Context, reason string) (bool, error) {
+	if st.confirm == nil {
+		return false, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "\033[93m%s\033[0m\n", reason)
+	if !st.confirm(ctx, "Log in again now? [Y/n] ") {
+		return false, nil
+	}
+
+	token, err := getDevToken(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	st.token = token
+	if cfg, err := loadConfig(); err == nil {
+		st.config = cfg
+	}
+	return true, nil
+}
+
+// askYesNo prints prompt and waits for an answer from con. An empty answer
+// counts as yes; EOF or cancellation as no.
+func askYesNo(ctx context.Context, con console, prompt string) bool {
+	line, err := con.readLine(ctx, prompt)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			fmt.Println()
+		}
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "" || answer == "y" || answer == "yes"
+}
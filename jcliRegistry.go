@@ -0,0 +1,893 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jam-launch/jam-cli/jamlaunch"
+)
+
+var errExit = errors.New("exit requested")
+
+type cliState struct {
+	token  string
+	config *Config
+	// ownsStdin is set when stdin carries the commands themselves, as for the
+	// prompt and batch scripts.
+	ownsStdin bool
+	// confirm asks the user a yes/no question. It is nil when nobody is there
+	// to answer, e.g. when stdin is not a terminal.
+	confirm func(ctx context.Context, prompt string) bool
+	// warnedToken is the token the expiry warning was last shown for.
+	warnedToken string
+	// projects caches the project list used to resolve project names.
+	projects projectResolver
+}
+
+type argSpec struct {
+	name     string
+	optional bool
+	// keyword arguments must be typed literally, e.g. "sessions"
+	keyword bool
+	// choices restricts the argument to one of the listed words
+	choices []string
+	// complete suggests values at the prompt; args are the positional
+	// arguments typed before this one
+	complete completionFunc
+}
+
+type flagSpec struct {
+	name  string
+	short string
+	// value names the flag's argument in help; boolean flags leave it empty
+	value string
+	usage string
+	// complete suggests values for flags that take one
+	complete completionFunc
+}
+
+type command struct {
+	name      string
+	aliases   []string
+	args      []argSpec
+	flags     []flagSpec
+	summary   string
+	details   []string
+	needsAuth bool
+	// authWhen, when set, limits needsAuth to the invocations it accepts.
+	authWhen func(args *commandArgs) bool
+	// scopes the developer token must have been granted to run the command
+	scopes          []string
+	interactiveOnly bool
+	run             func(ctx context.Context, st *cliState, args *commandArgs) error
+}
+
+// commandArgs holds a command line after it has been checked against the
+// command's argument and flag specs.
+type commandArgs struct {
+	positional []string
+	flags      map[string]string
+}
+
+func (a *commandArgs) arg(i int) string {
+	if i < len(a.positional) {
+		return a.positional[i]
+	}
+	return ""
+}
+
+func (a *commandArgs) flag(name string) string {
+	return a.flags[name]
+}
+
+func (a *commandArgs) has(name string) bool {
+	_, ok := a.flags[name]
+	return ok
+}
+
+var outputFlag = flagSpec{
+	name:  "output",
+	short: "o",
+	value: "format",
+	usage: "output format: " + strings.Join(outputFormats, ", "),
+	complete: func(st *cliState, args []string) []string {
+		return outputFormats
+	},
+}
+
+// asPlayerFlag lets a command authenticate with the player token instead of
+// the developer token. Commands offering it get the right one from tokenFor.
+var asPlayerFlag = flagSpec{
+	name:  "as-player",
+	usage: "use the player token from 'login --as-player'",
+}
+
+// projectFlag names the project for commands that otherwise act on the
+// current one chosen with 'use'.
+var projectFlag = flagSpec{
+	name:     "project",
+	short:    "p",
+	value:    "project",
+	usage:    "project to use instead of the current one set with 'use'",
+	complete: completeProjects,
+}
+
+// currentProject returns the project a command acts on: the one named with
+// --project, otherwise the active profile's current project.
+func (st *cliState) currentProject(args *commandArgs) (projectRef, error) {
+	if project := args.flag(projectFlag.name); project != "" {
+		return projectRef{query: project}, nil
+	}
+	if project, ok := st.config.profile(activeProfile).currentProject(); ok {
+		return project, nil
+	}
+	return projectRef{}, fmt.Errorf("no project selected; run 'use <project>' first or pass --project")
+}
+
+// devTokenNeeded reports whether running cmd with args needs the developer
+// token, which is not the case when it runs as a player.
+func (cmd *command) devTokenNeeded(args *commandArgs) bool {
+	if cmd.authWhen != nil && !cmd.authWhen(args) {
+		return false
+	}
+	return cmd.needsAuth && !args.has(asPlayerFlag.name)
+}
+
+func (st *cliState) tokenFor(ctx context.Context, args *commandArgs) (string, error) {
+	if args.has(asPlayerFlag.name) {
+		return st.playerToken(ctx)
+	}
+	return st.token, nil
+}
+
+type usageError struct {
+	cmd *command
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return fmt.Sprintf("%s\nusage: %s", e.msg, e.cmd.usage())
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{
+			name:    "help",
+			aliases: []string{"?"},
+			args:    []argSpec{{name: "command", optional: true, complete: completeCommands}},
+			summary: "Provides Help information for Jam Launch CLI commands.",
+			details: []string{
+				"Running help with parameters will display detailed help information for the command specified by the parameter.",
+			},
+			run: runHelp,
+		},
+		{
+			name: "login",
+			flags: []flagSpec{
+				{name: "with-token", usage: "read a token from stdin instead of logging in through the browser"},
+				{name: "as-player", usage: "log in as a player with the jam-play client"},
+				{name: "scope", value: "scopes", usage: "scopes to request, separated by spaces or commas (default developer, or player with --as-player)"},
+				{name: "json", usage: "print the user code and login URL as JSON instead of text"},
+				{name: "no-browser", usage: "do not open the login page in a browser"},
+				{name: "no-qr", usage: "do not show a QR code of the login URL"},
+			},
+			summary: "Prompts the user to log in again.",
+			details: []string{
+				"Running this command will prompt the user to generate a new authentication token and replace the old one regardless if it is valid or not.",
+				"For scripts and CI, 'jam-cli login --with-token < token.txt' stores a token without prompting. Setting JAMLAUNCH_TOKEN instead uses a token without storing it.",
+				"LOGIN --scope \"developer sessions\" requests other scopes. They are checked against the scopes the server offers, remembered for the profile and the granted scopes are shown afterwards.",
+				"The login URL is shown as a QR code and opened in your browser when there is one. Use --no-browser and --no-qr, or the no_browser and no_qr config keys, to turn this off. --json prints only the code and URL, as JSON, for other tools to present.",
+				"LOGIN --as-player signs in as a player instead. The player token is stored separately and used by commands run with --as-player, such as GET --as-player path.",
+			},
+			run: func(ctx context.Context, st *cliState, args *commandArgs) error {
+				asPlayer := args.has("as-player")
+
+				var token string
+				var err error
+				if args.has("with-token") {
+					if args.has("scope") {
+						return &usageError{cmd: findCommand("login"), msg: "login: --scope cannot be used with --with-token"}
+					}
+					if st.ownsStdin {
+						return fmt.Errorf("login --with-token reads the token from stdin; run it as 'jam-cli login --with-token' instead")
+					}
+					token, err = loginWithToken(ctx, os.Stdin, asPlayer)
+				} else {
+					saved := loginDisplay
+					defer func() { loginDisplay = saved }()
+					loginDisplay.json = loginDisplay.json || args.has("json")
+					loginDisplay.noBrowser = loginDisplay.noBrowser || args.has("no-browser") || args.has("json")
+					loginDisplay.noQR = loginDisplay.noQR || args.has("no-qr")
+					token, err = login(ctx, asPlayer, args.flag("scope"))
+				}
+				if err != nil {
+					return err
+				}
+				if !asPlayer {
+					st.token = token
+				}
+				if cfg, err := loadConfig(); err == nil {
+					st.config = cfg
+				}
+				return nil
+			},
+		},
+		{
+			name:    "logout",
+			args:    []argSpec{{name: "profile", optional: true, complete: completeProfiles}},
+			flags:   []flagSpec{{name: "all", usage: "log out of every profile"}},
+			summary: "Signs out, revoking the token and removing it from the config.",
+			details: []string{
+				"Running logout on its own signs out of the active profile. Name a profile to sign out of it instead, or use --all for every profile.",
+				"The token is revoked with the API where the server supports it, and always removed from the config file.",
+			},
+			run: func(ctx context.Context, st *cliState, args *commandArgs) error {
+				if args.has("all") && args.arg(0) != "" {
+					return &usageError{cmd: findCommand("logout"), msg: "logout: use either <profile> or --all, not both"}
+				}
+				return logout(ctx, st, args.arg(0), args.has("all"))
+			},
+		},
+		{
+			name:    "projects",
+			aliases: []string{"project"},
+			args: []argSpec{
+				{name: "project", optional: true, complete: completeProjects},
+				{name: "sessions", optional: true, keyword: true},
+				{name: "session-id", optional: true, complete: completeSessions},
+			},
+			summary: "Displays a list of the users current projects.",
+			details: []string{
+				"This command will display the id and name of each project in a table format.",
+				"Running projects with parameters will display more specific details about a specific project.",
+				"Running projects with parameters and the \"sessions\" keyword will display session information about the project.",
+				"A project can be given by id, by name in any case, or by the start of its name as long as only one project matches.",
+				"The project list is fetched once per session; use --refresh to fetch it again.",
+			},
+			flags: []flagSpec{
+				outputFlag,
+				{name: "refresh", usage: "fetch the project list again instead of using the session's cached copy"},
+			},
+			needsAuth: true,
+			scopes:    []string{defaultDevScope},
+			run: func(ctx context.Context, st *cliState, args *commandArgs) error {
+				format, err := st.outputFormat(args)
+				if err != nil {
+					return err
+				}
+
+				if args.has("refresh") {
+					st.projects.invalidate()
+				}
+
+				switch len(args.positional) {
+				case 0:
+					return projects(ctx, st, format)
+				case 1:
+					return projectsName(ctx, st, args.arg(0), format)
+				case 2:
+					return projectSessions(ctx, st, projectRef{query: args.arg(0)}, format)
+				}
+				return projectSessionId(ctx, st, projectRef{query: args.arg(0)}, args.arg(2), format)
+			},
+		},
+		{
+			name:    "use",
+			args:    []argSpec{{name: "project", optional: true, complete: completeProjects}},
+			flags:   []flagSpec{{name: "clear", usage: "forget the current project"}},
+			summary: "Sets the current project used by sessions, releases and members.",
+			details: []string{
+				"USE project makes the project current for the active profile. It is shown in the prompt and remembered across sessions.",
+				"Running use on its own shows the current project; USE --clear forgets it.",
+				"Commands that act on the current project accept --project to use another one for a single run.",
+				"Only USE project needs a login; showing or clearing the current project works offline.",
+			},
+			needsAuth: true,
+			// Showing and clearing the current project read only the config.
+			authWhen: func(args *commandArgs) bool { return args.arg(0) != "" && !args.has("clear") },
+			scopes:   []string{defaultDevScope},
+			run: func(ctx context.Context, st *cliState, args *commandArgs) error {
+				if args.has("clear") {
+					if args.arg(0) != "" {
+						return &usageError{cmd: findCommand("use"), msg: "use: use either <project> or --clear, not both"}
+					}
+					return clearProject(st)
+				}
+				if args.arg(0) == "" {
+					if project, ok := st.config.profile(activeProfile).currentProject(); ok {
+						if project.id != "" {
+							fmt.Printf("\033[93mCurrent project:\033[0m %s (%s)\n", project.name, project.id)
+						} else {
+							fmt.Printf("\033[93mCurrent project:\033[0m %s\n", project.query)
+						}
+						return nil
+					}
+					fmt.Println("No project selected; run 'use <project>' to pick one.")
+					return nil
+				}
+				return useProject(ctx, st, args.arg(0))
+			},
+		},
+		{
+			name:    "sessions",
+			args:    []argSpec{{name: "session-id", optional: true, complete: completeCurrentSessions}},
+			flags:   []flagSpec{outputFlag, projectFlag},
+			summary: "Displays the sessions of the current project.",
+			details: []string{
+				"Lists the sessions of the project chosen with 'use', like PROJECTS project SESSIONS does.",
+				"Running sessions with a session id displays that session and its players.",
+			},
+			needsAuth: true,
+			scopes:    []string{defaultDevScope},
+			run: func(ctx context.Context, st *cliState, args *commandArgs) error {
+				format, err := st.outputFormat(args)
+				if err != nil {
+					return err
+				}
+				project, err := st.currentProject(args)
+				if err != nil {
+					return err
+				}
+
+				if args.arg(0) == "" {
+					return projectSessions(ctx, st, project, format)
+				}
+				return projectSessionId(ctx, st, project, args.arg(0), format)
+			},
+		},
+		{
+			name:      "releases",
+			flags:     []flagSpec{outputFlag, projectFlag},
+			summary:   "Displays the releases of the current project.",
+			details:   []string{"Lists the releases of the project chosen with 'use'."},
+			needsAuth: true,
+			scopes:    []string{defaultDevScope},
+			run: func(ctx context.Context, st *cliState, args *commandArgs) error {
+				format, err := st.outputFormat(args)
+				if err != nil {
+					return err
+				}
+				project, err := st.currentProject(args)
+				if err != nil {
+					return err
+				}
+				return projectReleases(ctx, st, project, format)
+			},
+		},
+		{
+			name:      "members",
+			flags:     []flagSpec{outputFlag, projectFlag},
+			summary:   "Displays the members of the current project.",
+			details:   []string{"Lists the users with access to the project chosen with 'use'."},
+			needsAuth: true,
+			scopes:    []string{defaultDevScope},
+			run: func(ctx context.Context, st *cliState, args *commandArgs) error {
+				format, err := st.outputFormat(args)
+				if err != nil {
+					return err
+				}
+				project, err := st.currentProject(args)
+				if err != nil {
+					return err
+				}
+				return projectMembers(ctx, st, project, format)
+			},
+		},
+		{
+			name:    "get",
+			args:    []argSpec{{name: "path"}},
+			flags:   []flagSpec{asPlayerFlag},
+			summary: "Sends a GET request to the JamLaunch API and prints the JSON response.",
+			details: []string{
+				"The path is relative to the API base URL, e.g. \"get projects\".",
+				"With --as-player the request is sent with the token from 'login --as-player'.",
+			},
+			needsAuth: true,
+			run: func(ctx context.Context, st *cliState, args *commandArgs) error {
+				token, err := st.tokenFor(ctx, args)
+				if err != nil {
+					return err
+				}
+				return apiGet(ctx, args.arg(0), token)
+			},
+		},
+		{
+			name:    "game-get",
+			args:    []argSpec{{name: "project-release"}, {name: "path"}},
+			summary: "Sends a GET request to the JamLaunch API using a game test token.",
+			details: []string{
+				"The first parameter identifies the game as <project id>-<release id>.",
+				"A test token is requested for that release and then used to GET the path.",
+			},
+			needsAuth: true,
+			scopes:    []string{defaultDevScope},
+			run: func(ctx context.Context, st *cliState, args *commandArgs) error {
+				gameToken, err := getGameUserToken(ctx, args.arg(0), st.token)
+				if err != nil {
+					return fmt.Errorf("failed: %w", err)
+				}
+				return apiGet(ctx, args.arg(1), gameToken)
+			},
+		},
+		{
+			name:    "whoami",
+			summary: "Shows who the current token belongs to and when it expires.",
+			details: []string{
+				"Displays the user, scopes and issuer recorded in the active profile's token, and the time left until it expires.",
+				"With --as-player the player token from 'login --as-player' is described instead.",
+			},
+			flags:     []flagSpec{outputFlag, asPlayerFlag},
+			needsAuth: true,
+			run: func(ctx context.Context, st *cliState, args *commandArgs) error {
+				format, err := st.outputFormat(args)
+				if err != nil {
+					return err
+				}
+				token, err := st.tokenFor(ctx, args)
+				if err != nil {
+					return err
+				}
+				return whoami(token, format)
+			},
+		},
+		{
+			name: "token",
+			args: []argSpec{
+				{name: "action", choices: []string{"inspect"}},
+				{name: "jwt", optional: true},
+			},
+			flags: []flagSpec{
+				outputFlag,
+				{name: "game", value: "project-release", usage: "inspect a new game test token for the release"},
+			},
+			summary: "Decodes a JWT and shows its header, claims and signature status.",
+			details: []string{
+				"TOKEN INSPECT jwt pretty-prints any JWT. Timestamps such as exp and iat are shown as dates.",
+				"Without a jwt the active profile's token is inspected, even if it has expired.",
+				"TOKEN INSPECT --game project-release requests a game test token, as game-get does, and inspects it.",
+				"The signature is checked against the environment's published keys and reported as valid, invalid or unverified.",
+			},
+			run: func(ctx context.Context, st *cliState, args *commandArgs) error {
+				format, err := st.outputFormat(args)
+				if err != nil {
+					return err
+				}
+
+				jwt := args.arg(1)
+				if game := args.flag("game"); game != "" {
+					if jwt != "" {
+						return &usageError{cmd: findCommand("token"), msg: "token inspect: use either <jwt> or --game, not both"}
+					}
+					if st.token == "" {
+						if ok, token := loadToken(ctx); ok {
+							st.token = token
+						}
+					}
+					if st.token == "" {
+						return fmt.Errorf("profile %s is not logged in; run 'login' first", activeProfile)
+					}
+					jwt, err = getGameUserToken(ctx, game, st.token)
+					if err != nil {
+						return err
+					}
+				}
+
+				if jwt == "" {
+					jwt = firstNonEmpty(st.token, os.Getenv(tokenEnvVar), st.config.profile(activeProfile).Token)
+					if jwt == "" {
+						return fmt.Errorf("profile %s has no token to inspect; pass a <jwt> or run 'login'", activeProfile)
+					}
+				}
+
+				return tokenInspect(ctx, jwt, format)
+			},
+		},
+		{
+			name: "profile",
+			args: []argSpec{
+				{name: "action", choices: []string{"list", "use", "remove"}},
+				{name: "name", optional: true, complete: completeProfiles},
+			},
+			flags: []flagSpec{
+				outputFlag,
+				{name: "env", value: "environment", usage: "with use: set the profile's environment", complete: completeEnvironments},
+				{name: "project", value: "project", usage: "with use: set the profile's default project", complete: completeProjects},
+			},
+			summary: "Lists, switches between and removes named accounts.",
+			details: []string{
+				"Each profile has its own token, environment and default project.",
+				"PROFILE LIST shows all profiles; the active one is marked with *.",
+				"PROFILE USE name switches to a profile, creating it if needed. Run LOGIN afterwards to authenticate a new profile.",
+				"PROFILE REMOVE name deletes a profile and its token.",
+				"Start jam-cli with --profile name (or JAMLAUNCH_PROFILE) to use a profile for one session only.",
+			},
+			run: func(ctx context.Context, st *cliState, args *commandArgs) error {
+				action := strings.ToLower(args.arg(0))
+				if action == "list" {
+					format, err := st.outputFormat(args)
+					if err != nil {
+						return err
+					}
+					return profileList(st, format)
+				}
+
+				if args.arg(1) == "" {
+					return &usageError{cmd: findCommand("profile"), msg: fmt.Sprintf("profile %s: missing <name>", action)}
+				}
+				if action == "use" {
+					return profileUse(st, args.arg(1), args.flag("env"), args.flag("project"))
+				}
+				return profileRemove(st, args.arg(1))
+			},
+		},
+		{
+			name:            "exit",
+			aliases:         []string{"quit"},
+			summary:         "Leaves the interactive prompt.",
+			details:         []string{"In a script piped to stdin, EXIT ends the script early with the status so far."},
+			interactiveOnly: true,
+			run: func(ctx context.Context, st *cliState, args *commandArgs) error {
+				return errExit
+			},
+		},
+	}
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func findCommand(name string) *command {
+	name = strings.ToLower(name)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+		for _, alias := range cmd.aliases {
+			if alias == name {
+				return cmd
+			}
+		}
+	}
+	return nil
+}
+
+func (cmd *command) usage() string {
+	var b strings.Builder
+	b.WriteString(cmd.name)
+	if len(cmd.flags) > 0 {
+		b.WriteString(" [flags]")
+	}
+
+	closing := 0
+	for _, arg := range cmd.args {
+		b.WriteString(" ")
+		if arg.optional {
+			b.WriteString("[")
+			closing++
+		}
+		if arg.keyword {
+			b.WriteString(arg.name)
+		} else if len(arg.choices) > 0 {
+			b.WriteString(strings.Join(arg.choices, "|"))
+		} else {
+			b.WriteString("<" + arg.name + ">")
+		}
+	}
+	b.WriteString(strings.Repeat("]", closing))
+
+	return b.String()
+}
+
+// splitCommandLine splits a line typed at the prompt into words the way a
+// POSIX shell would: whitespace separates words, single quotes keep
+// everything literally, double quotes allow \" and \\ escapes, and a
+// backslash outside quotes escapes the next character.
+func splitCommandLine(line string) ([]string, error) {
+	scan := scanCommandLine([]rune(line))
+	if scan.quote != 0 {
+		return nil, &quoteError{quote: scan.quote}
+	}
+	if scan.escape {
+		return nil, &quoteError{quote: '\\'}
+	}
+
+	words := []string{}
+	for _, w := range scan.words {
+		words = append(words, w.text)
+	}
+	return words, nil
+}
+
+// quoteError reports a command line that ends inside a quoted word, or with
+// a backslash that has nothing left to escape (quote is then '\\').
+type quoteError struct {
+	quote rune
+}
+
+func (e *quoteError) Error() string {
+	if e.quote == '\\' {
+		return "trailing backslash escapes nothing"
+	}
+	return fmt.Sprintf("unterminated %c quote", e.quote)
+}
+
+type lineWord struct {
+	text string
+	// start is the index of the word's first rune, including any quote.
+	start int
+}
+
+// lineScan is the result of scanning a possibly unfinished command line.
+type lineScan struct {
+	words []lineWord
+	// open is set when the last word runs to the end of the line, i.e. it
+	// is still being typed.
+	open bool
+	// quote is the quote left unterminated at the end of the line, if any.
+	quote rune
+	// escape is set when the line ends with an unquoted backslash.
+	escape bool
+}
+
+func scanCommandLine(runes []rune) lineScan {
+	var scan lineScan
+	var word strings.Builder
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if !scan.open && !isLineSpace(r) {
+			scan.open = true
+			scan.words = append(scan.words, lineWord{start: i})
+		}
+
+		switch {
+		case scan.quote == '\'':
+			if r == '\'' {
+				scan.quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case scan.quote == '"':
+			if r == '"' {
+				scan.quote = 0
+			} else if r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+				i++
+				word.WriteRune(runes[i])
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			scan.quote = r
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				word.WriteRune(runes[i])
+			} else {
+				scan.escape = true
+			}
+		case isLineSpace(r):
+			if scan.open {
+				scan.words[len(scan.words)-1].text = word.String()
+				word.Reset()
+				scan.open = false
+			}
+		default:
+			word.WriteRune(r)
+		}
+	}
+
+	if scan.open {
+		scan.words[len(scan.words)-1].text = word.String()
+	}
+
+	return scan
+}
+
+func isLineSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// parseArgs splits the words following the command name into flags and
+// positional arguments and validates them against the command's specs, so
+// handlers can read arguments without bounds checks.
+func (cmd *command) parseArgs(words []string) (*commandArgs, error) {
+	args := &commandArgs{flags: map[string]string{}}
+
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+
+		if word == "--" {
+			args.positional = append(args.positional, words[i+1:]...)
+			break
+		}
+		if len(word) < 2 || word[0] != '-' {
+			args.positional = append(args.positional, word)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(word, "-"), "=")
+		spec := cmd.findFlag(name)
+		if spec == nil {
+			return nil, &usageError{cmd: cmd, msg: fmt.Sprintf("%s: unknown flag %s", cmd.name, word)}
+		}
+
+		if spec.value == "" {
+			if hasValue {
+				return nil, &usageError{cmd: cmd, msg: fmt.Sprintf("%s: flag --%s does not take a value", cmd.name, spec.name)}
+			}
+			args.flags[spec.name] = "true"
+			continue
+		}
+
+		if !hasValue {
+			if i+1 >= len(words) {
+				return nil, &usageError{cmd: cmd, msg: fmt.Sprintf("%s: flag --%s needs a <%s>", cmd.name, spec.name, spec.value)}
+			}
+			i++
+			value = words[i]
+		}
+		args.flags[spec.name] = value
+	}
+
+	if err := cmd.checkArgs(args.positional); err != nil {
+		return nil, err
+	}
+
+	return args, nil
+}
+
+func (cmd *command) findFlag(name string) *flagSpec {
+	for i := range cmd.flags {
+		if cmd.flags[i].name == name || (cmd.flags[i].short != "" && cmd.flags[i].short == name) {
+			return &cmd.flags[i]
+		}
+	}
+	return nil
+}
+
+func (cmd *command) checkArgs(args []string) error {
+	required := 0
+	for _, arg := range cmd.args {
+		if !arg.optional {
+			required++
+		}
+	}
+
+	if len(args) < required {
+		return &usageError{cmd: cmd, msg: fmt.Sprintf("%s: missing <%s>", cmd.name, cmd.args[len(args)].name)}
+	}
+	if len(args) > len(cmd.args) {
+		return &usageError{cmd: cmd, msg: fmt.Sprintf("%s: too many arguments", cmd.name)}
+	}
+
+	for i, arg := range args {
+		spec := cmd.args[i]
+		if spec.keyword && !strings.EqualFold(arg, spec.name) {
+			return &usageError{cmd: cmd, msg: fmt.Sprintf("%s: expected %q, got %q", cmd.name, spec.name, arg)}
+		}
+		if len(spec.choices) > 0 && !containsFold(spec.choices, arg) {
+			return &usageError{cmd: cmd, msg: fmt.Sprintf("%s: <%s> must be one of %s, got %q", cmd.name, spec.name, strings.Join(spec.choices, ", "), arg)}
+		}
+	}
+
+	return nil
+}
+
+// runCommand dispatches a single command line, already split into words, to
+// its handler. It is shared by the interactive prompt, batch scripts and
+// one-shot mode.
+func runCommand(ctx context.Context, st *cliState, parts []string) error {
+	cmd := findCommand(parts[0])
+	if cmd == nil {
+		return fmt.Errorf("%s: %w", parts[0], errUnknownCommand)
+	}
+
+	args, err := cmd.parseArgs(parts[1:])
+	if err != nil {
+		return err
+	}
+
+	if cmd.devTokenNeeded(args) && st.token == "" {
+		return fmt.Errorf("profile %s is not logged in; run 'login' first", activeProfile)
+	}
+
+	if cmd.devTokenNeeded(args) {
+		if err := st.checkExpiry(); err != nil {
+			renewed, authErr := st.reauthenticate(ctx, "Your token has expired.")
+			if authErr != nil {
+				return authErr
+			}
+			if !renewed {
+				return fmt.Errorf("%w; run 'login' to authenticate again", err)
+			}
+		}
+	}
+
+	if cmd.devTokenNeeded(args) {
+		if err := checkScopes(cmd, st.token); err != nil {
+			return err
+		}
+	}
+
+	err = cmd.run(ctx, st, args)
+	if cmd.devTokenNeeded(args) && tokenRejected(err) {
+		renewed, authErr := st.reauthenticate(ctx, "The API rejected your token.")
+		if authErr != nil {
+			return authErr
+		}
+		if renewed {
+			err = cmd.run(ctx, st, args)
+		}
+	}
+	if errors.Is(err, jamlaunch.ErrUnauthorized) {
+		return fmt.Errorf("%w\nThe API rejected your token; run 'login' to authenticate again.", err)
+	}
+	if errors.Is(err, jamlaunch.ErrForbidden) {
+		return fmt.Errorf("%w\nYour token does not give access to this; 'whoami' shows the scopes it was granted.", err)
+	}
+
+	return err
+}
+
+func runHelp(ctx context.Context, st *cliState, args *commandArgs) error {
+	if len(args.positional) == 0 {
+		fmt.Println("For more information on a specific command, type HELP command-name")
+
+		sorted := make([]*command, len(commands))
+		copy(sorted, commands)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+
+		for _, cmd := range sorted {
+			fmt.Printf("%-12s%s\n", strings.ToUpper(cmd.name), cmd.summary)
+		}
+		return nil
+	}
+
+	cmd := findCommand(args.arg(0))
+	if cmd == nil {
+		return fmt.Errorf("%s: %w. Use 'HELP' or 'HELP command-name'", args.arg(0), errUnknownCommand)
+	}
+
+	fmt.Printf("%s command details:\n", strings.ToUpper(cmd.name))
+	fmt.Println(cmd.summary)
+	fmt.Println("")
+	fmt.Printf("Usage: %s\n", cmd.usage())
+	if len(cmd.aliases) > 0 {
+		fmt.Printf("Aliases: %s\n", strings.Join(cmd.aliases, ", "))
+	}
+	if len(cmd.flags) > 0 {
+		fmt.Println("")
+		fmt.Println("Flags:")
+		for _, f := range cmd.flags {
+			names := "--" + f.name
+			if f.short != "" {
+				names = "-" + f.short + ", " + names
+			}
+			if f.value != "" {
+				names += " <" + f.value + ">"
+			}
+			fmt.Printf("  %-26s%s\n", names, f.usage)
+		}
+	}
+	if len(cmd.details) > 0 {
+		fmt.Println("")
+		for _, line := range cmd.details {
+			fmt.Println(line)
+		}
+	}
+
+	return nil
+}
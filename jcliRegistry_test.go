@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    []string
+		wantErr string
+	}{
+		{"plain words", "projects  p2\tsessions", []string{"projects", "p2", "sessions"}, ""},
+		{"blank line", "   ", []string{}, ""},
+		{"double quotes", `projects "Space Jam"`, []string{"projects", "Space Jam"}, ""},
+		{"single quotes keep backslashes", `use 'a\b "c"'`, []string{"use", `a\b "c"`}, ""},
+		{"escapes in double quotes", `use "say \"hi\" \\ \n"`, []string{"use", `say "hi" \ \n`}, ""},
+		{"escaped space", `use Space\ Jam`, []string{"use", "Space Jam"}, ""},
+		{"escaped quote", `use it\'s`, []string{"use", "it's"}, ""},
+		{"quotes inside a word", `use Space" "'Jam'`, []string{"use", "Space Jam"}, ""},
+		{"empty double quotes", `use ""`, []string{"use", ""}, ""},
+		{"empty single quotes between words", `a '' b`, []string{"a", "", "b"}, ""},
+		{"flag with value", `sessions --output=json -p "My Game"`, []string{"sessions", "--output=json", "-p", "My Game"}, ""},
+		{"quoted flag value", `sessions --project="Space Jam"`, []string{"sessions", "--project=Space Jam"}, ""},
+		{"double dash", `use -- --clear`, []string{"use", "--", "--clear"}, ""},
+		{"unterminated double quote", `use "Space`, nil, `unterminated " quote`},
+		{"unterminated single quote", `use 'Space`, nil, `unterminated ' quote`},
+		{"trailing backslash", `use Space\`, nil, "trailing backslash escapes nothing"},
+		{"lone trailing backslash", `use \`, nil, "trailing backslash escapes nothing"},
+		{"escaped trailing backslash", `use Space\\`, []string{"use", `Space\`}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitCommandLine(tt.line)
+			if tt.wantErr != "" {
+				var quoteErr *quoteError
+				if !errors.As(err, &quoteErr) || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want quoteError %q", err, tt.wantErr)
+				}
+				if exitStatus(err) != exitUsage {
+					t.Errorf("exitStatus = %d, want %d", exitStatus(err), exitUsage)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("splitCommandLine: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("words = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	cmd := findCommand("sessions")
+	if cmd == nil {
+		t.Fatal("sessions command not found")
+	}
+
+	tests := []struct {
+		name       string
+		line       string
+		positional []string
+		flags      map[string]string
+		usage      bool
+	}{
+		{"no arguments", ``, []string{}, map[string]string{}, false},
+		{"flag with equals", `--output=json`, []string{}, map[string]string{"output": "json"}, false},
+		{"flag with separate value", `-o yaml s-1`, []string{"s-1"}, map[string]string{"output": "yaml"}, false},
+		{"quoted value with equals", `--project="Space Jam" s-1`, []string{"s-1"}, map[string]string{"project": "Space Jam"}, false},
+		{"empty value", `--project ""`, []string{}, map[string]string{"project": ""}, false},
+		{"double dash ends flags", `-- --output`, []string{"--output"}, map[string]string{}, false},
+		{"empty positional", `""`, []string{""}, map[string]string{}, false},
+		{"unknown flag", `--nope`, nil, nil, true},
+		{"missing flag value", `--output`, nil, nil, true},
+		{"too many arguments", `s-1 s-2`, nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words, err := splitCommandLine(tt.line)
+			if err != nil {
+				t.Fatalf("splitCommandLine: %v", err)
+			}
+
+			args, err := cmd.parseArgs(words)
+			if tt.usage {
+				var usageErr *usageError
+				if !errors.As(err, &usageErr) {
+					t.Fatalf("err = %v, want a usage error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseArgs: %v", err)
+			}
+			if len(args.positional) != len(tt.positional) || (len(tt.positional) > 0 && !reflect.DeepEqual(args.positional, tt.positional)) {
+				t.Errorf("positional = %q, want %q", args.positional, tt.positional)
+			}
+			if !reflect.DeepEqual(args.flags, tt.flags) {
+				t.Errorf("flags = %q, want %q", args.flags, tt.flags)
+			}
+		})
+	}
+}
+
+func TestUseNeedsAuthOnlyToSelectProject(t *testing.T) {
+	cmd := findCommand("use")
+
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{``, false},
+		{`--clear`, false},
+		{`MyGame`, true},
+		{`"Space Jam"`, true},
+	}
+
+	for _, tt := range tests {
+		words, err := splitCommandLine(tt.line)
+		if err != nil {
+			t.Fatalf("splitCommandLine(%q): %v", tt.line, err)
+		}
+		args, err := cmd.parseArgs(words)
+		if err != nil {
+			t.Fatalf("parseArgs(%q): %v", tt.line, err)
+		}
+		if got := cmd.devTokenNeeded(args); got != tt.want {
+			t.Errorf("use %s: devTokenNeeded = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jam-launch/jam-cli/jamlaunch"
+)
+
+// projectResolver turns what the user typed into a project. The project list
+// is fetched once per session and token, and reused until invalidate is
+// called. The session ids last listed for each project are kept alongside
+// for tab completion.
+type projectResolver struct {
+	token    string
+	projects []jamlaunch.ProjectSummary
+	sessions map[string][]string
+}
+
+func (r *projectResolver) invalidate() {
+	r.token = ""
+	r.projects = nil
+	r.sessions = nil
+}
+
+// list returns the cached project list, fetching it when the cache is empty
+// or belongs to another token.
+func (r *projectResolver) list(ctx context.Context, client *jamlaunch.Client) ([]jamlaunch.ProjectSummary, error) {
+	if r.projects != nil && r.token == client.Token {
+		return r.projects, nil
+	}
+
+	projects, err := client.ListProjects(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error: unable to retrieve projects: %w", err)
+	}
+
+	r.remember(client.Token, projects)
+	return projects, nil
+}
+
+func (r *projectResolver) remember(token string, projects []jamlaunch.ProjectSummary) {
+	if token != r.token {
+		r.sessions = nil
+	}
+	r.token = token
+	r.projects = projects
+}
+
+func (r *projectResolver) rememberSessions(projectId string, sessions []jamlaunch.SessionSummary) {
+	if r.sessions == nil {
+		r.sessions = map[string][]string{}
+	}
+	ids := []string{}
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+	}
+	r.sessions[projectId] = ids
+}
+
+// resolve accepts a project id, an exact name, a name in any case or a
+// unique prefix of a name, in that order of preference.
+func (r *projectResolver) resolve(ctx context.Context, client *jamlaunch.Client, query string) (jamlaunch.ProjectSummary, error) {
+	projects, err := r.list(ctx, client)
+	if err != nil {
+		return jamlaunch.ProjectSummary{}, err
+	}
+	return matchProject(projects, query)
+}
+
+func matchProject(projects []jamlaunch.ProjectSummary, query string) (jamlaunch.ProjectSummary, error) {
+	matchers := []func(p jamlaunch.ProjectSummary) bool{
+		func(p jamlaunch.ProjectSummary) bool { return p.ID == query },
+		func(p jamlaunch.ProjectSummary) bool { return p.Name == query },
+		func(p jamlaunch.ProjectSummary) bool { return strings.EqualFold(p.Name, query) },
+		func(p jamlaunch.ProjectSummary) bool {
+			return strings.HasPrefix(strings.ToLower(p.Name), strings.ToLower(query))
+		},
+	}
+
+	for _, match := range matchers {
+		found := []jamlaunch.ProjectSummary{}
+		for _, p := range projects {
+			if match(p) {
+				found = append(found, p)
+			}
+		}
+
+		if len(found) == 1 {
+			return found[0], nil
+		}
+		if len(found) > 1 {
+			names := []string{}
+			for _, p := range found {
+				names = append(names, fmt.Sprintf("%s (%s)", p.Name, p.ID))
+			}
+			return jamlaunch.ProjectSummary{}, fmt.Errorf("error: project %q is ambiguous; it matches %s. Use more of the name or the project id", query, strings.Join(names, ", "))
+		}
+	}
+
+	return jamlaunch.ProjectSummary{}, unknownProjectError(query, projects)
+}
+
+// projectRef names a project either by the id stored with 'use', which is
+// sent to the API as it is, or by what the user typed, which is resolved
+// against the project list first.
+type projectRef struct {
+	id    string
+	name  string
+	query string
+}
+
+// String is the project's name, or what was typed when it is not resolved.
+func (ref projectRef) String() string {
+	return firstNonEmpty(ref.name, ref.query, ref.id)
+}
+
+// withRef runs fn with the project's id, resolving ref first unless it
+// already holds one.
+func (r *projectResolver) withRef(ctx context.Context, client *jamlaunch.Client, ref projectRef, fn func(projectId string) error) error {
+	if ref.id != "" {
+		return fn(ref.id)
+	}
+	return r.withProject(ctx, client, ref.query, fn)
+}
+
+// withProject resolves query and runs fn with the project's id. When the API
+// no longer knows that project the cached list is stale, so it is dropped and
+// the name resolved once more.
+func (r *projectResolver) withProject(ctx context.Context, client *jamlaunch.Client, query string, fn func(projectId string) error) error {
+	project, err := r.resolve(ctx, client, query)
+	if err != nil {
+		return err
+	}
+
+	err = fn(project.ID)
+	if !errors.Is(err, jamlaunch.ErrNotFound) {
+		return err
+	}
+
+	r.invalidate()
+	fresh, resolveErr := r.resolve(ctx, client, query)
+	if resolveErr != nil {
+		return resolveErr
+	}
+	if fresh.ID == project.ID {
+		return err
+	}
+	return fn(fresh.ID)
+}
+
+func unknownProjectError(query string, projects []jamlaunch.ProjectSummary) error {
+	type candidate struct {
+		name     string
+		distance int
+	}
+
+	lower := strings.ToLower(query)
+	candidates := []candidate{}
+	for _, p := range projects {
+		name := strings.ToLower(p.Name)
+		// Compare against the start of the name too, so typos in a prefix
+		// still find the project.
+		d := editDistance(lower, name)
+		if r := []rune(name); len(r) > len([]rune(lower)) {
+			d = min(d, editDistance(lower, string(r[:len([]rune(lower))])))
+		}
+		if strings.Contains(name, lower) || d <= max(2, len(lower)/3) {
+			candidates = append(candidates, candidate{p.Name, d})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+
+	if len(candidates) == 0 {
+		return fmt.Errorf("error: project %q not found; run 'projects' to list your projects", query)
+	}
+
+	names := []string{}
+	for i, c := range candidates {
+		if i == 5 {
+			break
+		}
+		names = append(names, fmt.Sprintf("%q", c.name))
+	}
+	return fmt.Errorf("error: project %q not found; did you mean %s?", query, strings.Join(names, ", "))
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultDevScope    = "developer"
+	defaultPlayerScope = "player"
+)
+
+// normalizeScopes accepts scopes separated by spaces or commas and returns
+// them space separated, as OAuth expects.
+func normalizeScopes(scope string) string {
+	return strings.Join(strings.FieldsFunc(scope, func(r rune) bool {
+		return r == ',' || r == ' '
+	}), " ")
+}
+
+// devScope is the scope the active profile logs in with: the one last passed
+// to 'login --scope', or developer.
+func devScope() string {
+	cfg, err := loadConfig()
+	if err != nil {
+		return defaultDevScope
+	}
+	return firstNonEmpty(cfg.profile(activeProfile).Scope, defaultDevScope)
+}
+
+// supportedScopes reads scopes_supported from the environment's OAuth
+// discovery document.
+func supportedScopes(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, clientSettings.timeout)
+	defer cancel()
+
+	discoveryUrl := activeEnv.apiUrl(".well-known/openid-configuration")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", discoveryUrl, resp.Status)
+	}
+
+	var discovery struct {
+		ScopesSupported []string `json:"scopes_supported"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return nil, fmt.Errorf("invalid discovery document: %w", err)
+	}
+	if len(discovery.ScopesSupported) == 0 {
+		return nil, fmt.Errorf("%s does not list scopes_supported", discoveryUrl)
+	}
+
+	return discovery.ScopesSupported, nil
+}
+
+// validateScopes checks the requested scopes against the ones the server
+// offers. When the server does not publish them the request is let through.
+func validateScopes(ctx context.Context, scope string) error {
+	offered, err := supportedScopes(ctx)
+	if err != nil {
+		fmt.Fprintf(flowOutput(), "\033[93mWarning: unable to check the requested scopes: %s\033[0m\n", err)
+		return nil
+	}
+
+	for _, s := range strings.Fields(scope) {
+		if !containsFold(offered, s) {
+			return fmt.Errorf("unknown scope %q; the server offers: %s", s, strings.Join(offered, ", "))
+		}
+	}
+	return nil
+}
+
+// printGrantedScopes shows the scopes recorded in a new token and points out
+// any that were requested but not granted.
+func printGrantedScopes(token string, requested string) {
+	result := parseToken(token)
+	if result.Errored {
+		return
+	}
+
+	granted := tokenScopes(result.Data.Claims)
+	if len(granted) == 0 {
+		return
+	}
+	fmt.Fprintf(flowOutput(), "\033[93mGranted scopes:\033[0m %s\n", strings.Join(granted, " "))
+
+	missing := []string{}
+	for _, s := range strings.Fields(requested) {
+		if !containsFold(granted, s) {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(flowOutput(), "\033[93mNot granted:\033[0m %s\n", strings.Join(missing, " "))
+	}
+}
+
+// checkScopes fails when cmd needs a scope the token was not granted. Tokens
+// without a scope claim are not checked; the API has the final say.
+func checkScopes(cmd *command, token string) error {
+	if len(cmd.scopes) == 0 {
+		return nil
+	}
+
+	result := parseToken(token)
+	if result.Errored {
+		return nil
+	}
+	if _, ok := result.Data.Claims["scope"]; !ok {
+		if _, ok := result.Data.Claims["scp"]; !ok {
+			return nil
+		}
+	}
+
+	granted := tokenScopes(result.Data.Claims)
+	for _, s := range cmd.scopes {
+		if !containsFold(granted, s) {
+			return fmt.Errorf("%s needs the %q scope, which your token was not granted; run 'login --scope \"%s\"' to request it", cmd.name, s, strings.Join(append(granted, s), " "))
+		}
+	}
+	return nil
+}
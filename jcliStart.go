@@ -2,101 +2,394 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
+
+	"github.com/jam-launch/jam-cli/jamlaunch"
+	"golang.org/x/term"
+)
+
+// version is overwritten at release time by goreleaser's default ldflags.
+var version = "dev"
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+	exitAuth  = 3
+	// exitLoginRequired means there was no usable token and none could be
+	// obtained without prompting, e.g. in CI.
+	exitLoginRequired = 4
+	// exitInterrupted follows the shell convention of 128 + SIGINT.
+	exitInterrupted = 130
 )
 
+var errUnknownCommand = errors.New("unknown command")
+
+// ciMode disables every interactive prompt, including the device login, so a
+// missing or expired token fails straight away with exitLoginRequired.
+var ciMode bool
+
 func printError(errStr error) {
 	if errStr != nil {
-		fmt.Printf("\033[91m%s\033[0m\n", errStr)
+		fmt.Fprintf(os.Stderr, "\033[91m%s\033[0m\n", errStr)
 	}
 }
 
+// isTerminal reports whether f is attached to an interactive terminal.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
 func main() {
-	// Step 1: Request Device Code
-	fmt.Println("Welcome to the JamLaunch CLI!")
+	flags := flag.NewFlagSet("jam-cli", flag.ContinueOnError)
+	showVersion := flags.Bool("version", false, "print the jam-cli version and exit")
+	envName := flags.String("env", "", "API environment to use: prod, staging, custom or one defined in the config file (env JAMLAUNCH_ENV)")
+	apiUrl := flags.String("api-url", "", "API base URL, selects the custom environment (env JAMLAUNCH_API_URL)")
+	appUrl := flags.String("app-url", "", "web app base URL used for device login (env JAMLAUNCH_APP_URL)")
+	profileName := flags.String("profile", "", "named profile to use for this session (env JAMLAUNCH_PROFILE)")
+	verbose := flags.Bool("verbose", false, "log retries and the remaining API rate limit to stderr")
+	timeout := flags.Duration("timeout", 0, "timeout for each API request, e.g. 10s (default 30s)")
+	ci := flags.Bool("ci", false, "never prompt; fail with exit code 4 when no valid token is available (default true when CI is set)")
+	keepGoing := flags.Bool("keep-going", false, "when running commands piped to stdin, carry on after a command fails")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: jam-cli [flags] [command [arguments]]")
+		fmt.Fprintln(flags.Output(), "")
+		fmt.Fprintln(flags.Output(), "Runs a single command when one is given, otherwise starts the interactive prompt.")
+		fmt.Fprintln(flags.Output(), "Commands piped to stdin, e.g. 'jam-cli < commands.txt', are run one line at a time.")
+		fmt.Fprintln(flags.Output(), "Use 'jam-cli help' to list the available commands.")
+		fmt.Fprintln(flags.Output(), "")
+		fmt.Fprintln(flags.Output(), "Flags:")
+		flags.PrintDefaults()
+	}
 
-	fmt.Print("Checking token...")
-	result, token := loadToken()
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitUsage)
+	}
 
-	if !result {
-		fmt.Println("\033[91mToken not found or invalid! User must authenticate again.\033[0m")
+	if *showVersion {
+		fmt.Println("jam-cli", version)
+		os.Exit(exitOK)
+	}
 
-		var err error
-		token, err = getDevToken()
-		if err != nil {
-			fmt.Printf("\033[31mFailed to get tokens: %v\n - exiting...\033[0m\n", err)
+	cfg, err := loadConfig()
+	if err != nil {
+		printError(err)
+		os.Exit(exitError)
+	}
+
+	envOverrides.name = *envName
+	envOverrides.apiUrl = *apiUrl
+	envOverrides.appUrl = *appUrl
+
+	if err := selectProfile(cfg, *profileName); err != nil {
+		printError(err)
+		os.Exit(exitUsage)
+	}
+
+	clientSettings.retry, err = cfg.Retry.policy()
+	if err != nil {
+		printError(err)
+		os.Exit(exitUsage)
+	}
+	clientSettings.verbose = *verbose
+	ciMode = *ci || envTrue("CI")
+	loginDisplay.noBrowser = cfg.NoBrowser || envTrue("JAMLAUNCH_NO_BROWSER")
+	loginDisplay.noQR = cfg.NoQR
+
+	clientSettings.timeout, err = cfg.requestTimeout(*timeout)
+	if err != nil {
+		printError(err)
+		os.Exit(exitUsage)
+	}
+
+	clockSkew, err = cfg.tokenClockSkew()
+	if err != nil {
+		printError(err)
+		os.Exit(exitUsage)
+	}
+
+	if flags.NArg() > 0 {
+		os.Exit(runOnce(cfg, flags.Args()))
+	}
+
+	if !isTerminal(os.Stdin) {
+		os.Exit(runBatch(cfg, os.Stdin, *keepGoing))
+	}
+
+	repl(cfg)
+}
+
+// runOnce executes a single command taken from the process arguments and
+// returns the exit status for the process.
+func runOnce(cfg *Config, args []string) int {
+	// The first Ctrl-C cancels the command; once it has, a second one falls
+	// through to the default handler and kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	st := &cliState{config: cfg}
+	if canPrompt() {
+		// Only start reading stdin if a question is actually asked.
+		var con console
+		st.confirm = func(ctx context.Context, prompt string) bool {
+			if con == nil {
+				con = &lineConsole{lines: readLines()}
+			}
+			return askYesNo(ctx, con, prompt)
 		}
+	}
+
+	var err error
+	if cmd := findCommand(args[0]); cmd != nil && cmd.interactiveOnly && !isHelpRequest(args) {
+		err = &usageError{cmd: cmd, msg: fmt.Sprintf("%s: only available at the interactive prompt or in a script", cmd.name)}
 	} else {
-		fmt.Printf("\033[92mLogin successful!\033[0m\n")
+		err = runArgs(ctx, st, args)
+	}
+	if err != nil {
+		printError(err)
+	}
+	if errors.Is(err, errUnknownCommand) {
+		fmt.Fprintln(os.Stderr, "Use 'jam-cli help' to list the available commands.")
 	}
 
-	reader := bufio.NewReader(os.Stdin)
+	return exitStatus(err)
+}
 
-	fmt.Println("Type your message below. Type 'exit' to quit.")
+func isHelpRequest(args []string) bool {
+	last := args[len(args)-1]
+	return len(args) > 1 && (last == "--help" || last == "-h")
+}
 
-	for {
-		// Display a prompt
-		fmt.Print("> ")
+// runArgs runs one command outside the interactive prompt. When the command
+// needs a developer token and st has none yet, the stored token is loaded,
+// or a new one obtained through the device login where that is allowed.
+func runArgs(ctx context.Context, st *cliState, args []string) error {
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		return fmt.Errorf("%s: %w", args[0], errUnknownCommand)
+	}
 
-		// Read user input
-		input, err := reader.ReadString('\n')
-		if err != nil {
-			fmt.Printf("\033[91mError reading input: %s\033[0m\n", err)
-			continue
+	if isHelpRequest(args) {
+		args = []string{"help", cmd.name}
+		cmd = findCommand("help")
+	}
+
+	parsed, err := cmd.parseArgs(args[1:])
+	if err != nil {
+		return err
+	}
+
+	if cmd.devTokenNeeded(parsed) && st.token == "" {
+		result, token := loadToken(ctx)
+		if !result {
+			fmt.Fprintln(os.Stderr, "\033[91mToken not found or invalid! User must authenticate again.\033[0m")
+			if !canLogin() {
+				return noTokenError()
+			}
+
+			token, err = getDevToken(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get tokens: %w", err)
+			}
 		}
+		st.token = token
+	}
 
-		// Trim whitespace
-		input = strings.TrimSpace(input)
+	return runCommand(ctx, st, args)
+}
 
-		if strings.ToLower(input) == "login" {
-			err = login()
+// exitStatus maps the error a command returned to the process exit status.
+func exitStatus(err error) int {
+	var usageErr *usageError
+	var quoteErr *quoteError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usageErr) || errors.As(err, &quoteErr) || errors.Is(err, errUnknownCommand):
+		return exitUsage
+	case errors.Is(err, errLoginRequired) || errors.Is(err, errTokenExpired):
+		return exitLoginRequired
+	case errors.Is(err, jamlaunch.ErrUnauthorized):
+		return exitAuth
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	}
+	return exitError
+}
 
-			printError(err)
-		} else if len(input) >= 8 && strings.ToLower(input[:8]) == "projects" {
-			parts := strings.Fields(input)
-			if len(parts) == 1 && strings.ToLower(parts[0]) == "projects" {
-				err = projects(token)
+type inputLine struct {
+	text string
+	err  error
+}
+
+// readLines reads stdin on its own goroutine so the prompt can wait for
+// input and Ctrl-C at the same time. The channel is closed after the first
+// read error.
+func readLines() <-chan inputLine {
+	lines := make(chan inputLine)
+
+	go func() {
+		defer close(lines)
+
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			text, err := reader.ReadString('\n')
+			lines <- inputLine{text: text, err: err}
+			if err != nil {
+				return
+			}
+		}
+	}()
 
-				printError(err)
-			} else if len(parts) == 2 {
-				err = projectsName(token, parts[1])
+	return lines
+}
 
-				printError(err)
-			} else if len(parts) == 3 {
-				err = projectSessions(token, parts[1])
+// interruptible runs fn with a context that the next Ctrl-C cancels. A second
+// Ctrl-C while fn is still running exits the CLI.
+func interruptible(interrupts <-chan os.Signal, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-				printError(err)
-			} else {
-				err = projectSessionId(token, parts[1], parts[3])
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
 
-				printError(err)
+	cancelled := false
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-interrupts:
+			if cancelled {
+				fmt.Println("\nGoodbye!")
+				os.Exit(exitInterrupted)
 			}
-		} else if len(input) >= 3 && strings.ToLower(input[:3]) == "get" {
-			parts := strings.Fields(input)
+			cancelled = true
+			fmt.Println("\n\033[93mCancelling... (press Ctrl-C again to quit)\033[0m")
+			cancel()
+		}
+	}
+}
 
-			err = apiGet(parts[1], token)
+func repl(cfg *Config) {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
 
-			printError(err)
-		} else if len(input) >= 8 && strings.ToLower(input[:8]) == "game-get" {
-			parts := strings.Fields(input)
-			gameToken, err := getGameUserToken(parts[1], token)
-			if err != nil {
-				fmt.Printf("\033[31mFailed: %v\033[0m\n", err)
+	// Step 1: Request Device Code
+	fmt.Println("Welcome to the JamLaunch CLI!")
+	if activeProfile != DefaultProfile {
+		fmt.Printf("\033[93mProfile:\033[0m %s\n", activeProfile)
+	}
+	if activeEnv.Name != DefaultEnvironment {
+		fmt.Printf("\033[93mEnvironment:\033[0m %s (%s)\n", activeEnv.Name, activeEnv.ApiBaseUrl)
+	}
+
+	st := &cliState{config: cfg, ownsStdin: true}
+
+	fmt.Print("Checking token...")
+	err := interruptible(interrupts, func(ctx context.Context) error {
+		result, token := loadToken(ctx)
+		if result {
+			fmt.Printf("\033[92mLogin successful!\033[0m\n")
+			st.token = token
+			return nil
+		}
+
+		fmt.Println("\033[91mToken not found or invalid! User must authenticate again.\033[0m")
+		if !canLogin() {
+			return noTokenError()
+		}
+
+		token, err := getDevToken(ctx)
+		if err != nil {
+			return err
+		}
+		st.token = token
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("\033[31mFailed to get tokens: %v\n - use 'login' to try again.\033[0m\n", err)
+	}
+
+	con := newConsole(st, interrupts)
+	defer con.close()
+	if canPrompt() {
+		st.confirm = func(ctx context.Context, prompt string) bool {
+			return askYesNo(ctx, con, prompt)
+		}
+	}
+
+	fmt.Println("Type your message below. Type 'exit' to quit.")
+
+	exitPending := false
+	for {
+		// Wait for user input or Ctrl-C
+		line, err := con.readLine(context.Background(), promptPrefix(st.config)+"> ")
+		if errors.Is(err, errPromptInterrupted) {
+			// Ctrl-C on a half-typed line only discards it.
+			if line != "" {
+				exitPending = false
 				continue
 			}
-			err = apiGet(parts[2], gameToken)
+			if exitPending {
+				fmt.Println("Goodbye!")
+				return
+			}
+			exitPending = true
+			fmt.Println("(press Ctrl-C again or type 'exit' to quit)")
+			continue
+		}
+		exitPending = false
+
+		if err != nil {
+			if err != io.EOF {
+				fmt.Printf("\033[91mError reading input: %s\033[0m\n", err)
+			}
+			fmt.Println("\nGoodbye!")
+			return
+		}
 
+		if strings.TrimSpace(line) != "" {
+			con.remember(line)
+		}
+
+		parts, err := splitCommandLine(line)
+		if err != nil {
 			printError(err)
-		} else if len(input) >= 4 && strings.ToLower(input[:4]) == "help" {
-			help(input)
-		} else if strings.ToLower(input) == "exit" {
+			continue
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		err = interruptible(interrupts, func(ctx context.Context) error {
+			return runCommand(ctx, st, parts)
+		})
+		if errors.Is(err, errExit) {
 			fmt.Println("Goodbye!")
-			break
-		} else {
-			fmt.Printf("\033[31m%s is not a valid command!\033[0m\n", input)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			fmt.Println("\033[93mCommand cancelled.\033[0m")
+			continue
 		}
+		printError(err)
 	}
 }
 
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/table"
+)
+
+// timeClaims are the registered claims that hold NumericDate values.
+var timeClaims = []string{"exp", "iat", "nbf", "auth_time"}
+
+type whoamiRecord struct {
+	Profile     string   `json:"profile" yaml:"profile"`
+	Environment string   `json:"environment" yaml:"environment"`
+	User        string   `json:"user" yaml:"user"`
+	Email       string   `json:"email,omitempty" yaml:"email,omitempty"`
+	Scopes      []string `json:"scopes" yaml:"scopes"`
+	Issuer      string   `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	ExpiresAt   string   `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	ExpiresIn   string   `json:"expires_in,omitempty" yaml:"expires_in,omitempty"`
+}
+
+type tokenRecord struct {
+	Header          map[string]interface{} `json:"header" yaml:"header"`
+	Claims          map[string]interface{} `json:"claims" yaml:"claims"`
+	Signature       string                 `json:"signature" yaml:"signature"`
+	SignatureDetail string                 `json:"signature_detail,omitempty" yaml:"signature_detail,omitempty"`
+	Times           map[string]string      `json:"times,omitempty" yaml:"times,omitempty"`
+}
+
+func claimString(claims map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := claims[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func claimTime(claims map[string]interface{}, key string) (time.Time, bool) {
+	v, ok := claims[key].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(v), 0), true
+}
+
+// tokenScopes reads the OAuth "scope" claim, which is space separated, or the
+// "scp"/"scopes" list used by some issuers.
+func tokenScopes(claims map[string]interface{}) []string {
+	if s, ok := claims["scope"].(string); ok {
+		return strings.Fields(s)
+	}
+
+	scopes := []string{}
+	for _, key := range []string{"scp", "scopes"} {
+		switch v := claims[key].(type) {
+		case string:
+			return strings.Fields(v)
+		case []interface{}:
+			for _, s := range v {
+				if s, ok := s.(string); ok {
+					scopes = append(scopes, s)
+				}
+			}
+			return scopes
+		}
+	}
+	return scopes
+}
+
+// relativeTime describes t relative to now, e.g. "in 1h30m0s" or "5m0s ago".
+func relativeTime(t time.Time) string {
+	d := time.Until(t).Round(time.Second)
+	if d >= 0 {
+		return "in " + d.String()
+	}
+	return (-d).String() + " ago"
+}
+
+func whoami(token string, format string) error {
+	result := parseToken(token)
+	if result.Errored {
+		return fmt.Errorf("unable to read the current token: %s", result.Error)
+	}
+	claims := result.Data.Claims
+
+	record := whoamiRecord{
+		Profile:     activeProfile,
+		Environment: activeEnv.Name,
+		User:        claimString(claims, "preferred_username", "username", "name", "sub"),
+		Email:       claimString(claims, "email"),
+		Scopes:      tokenScopes(claims),
+		Issuer:      claimString(claims, "iss"),
+	}
+	if exp, ok := claimTime(claims, "exp"); ok {
+		record.ExpiresAt = exp.UTC().Format(time.RFC3339)
+		record.ExpiresIn = time.Until(exp).Round(time.Second).String()
+	}
+
+	if format != formatTable {
+		rows := [][]string{{record.Profile, record.Environment, record.User, record.Email, strings.Join(record.Scopes, " "), record.Issuer, record.ExpiresAt, record.ExpiresIn}}
+		return emit(format, record, []string{"profile", "environment", "user", "email", "scopes", "issuer", "expires_at", "expires_in"}, rows)
+	}
+
+	expires := ""
+	if exp, ok := claimTime(claims, "exp"); ok {
+		expires = fmt.Sprintf("%s (%s)", exp.Local().Format(time.RFC1123), relativeTime(exp))
+	}
+
+	t := table.NewWriter()
+	t.SetTitle("Current User")
+	t.SetStyle(table.StyleColoredDark)
+	t.AppendRows([]table.Row{
+		{"User", record.User},
+		{"Email", record.Email},
+		{"Scopes", strings.Join(record.Scopes, " ")},
+		{"Issuer", record.Issuer},
+		{"Expires", expires},
+		{"Profile", record.Profile},
+		{"Environment", fmt.Sprintf("%s (%s)", activeEnv.Name, activeEnv.ApiBaseUrl)},
+	})
+
+	fmt.Println(t.Render())
+
+	return nil
+}
+
+// tokenInspect prints the header and claims of any JWT, with timestamps made
+// readable and the result of checking its signature against the active
+// environment's keys. The signature is reported, never enforced.
+func tokenInspect(ctx context.Context, jwt string, format string) error {
+	result := parseToken(strings.TrimSpace(jwt))
+	if result.Errored {
+		return fmt.Errorf("%s", result.Error)
+	}
+
+	status, err := checkSignature(ctx, result.Data)
+	record := tokenRecord{
+		Header:    integralNumbers(result.Data.Header).(map[string]interface{}),
+		Claims:    integralNumbers(result.Data.Claims).(map[string]interface{}),
+		Signature: string(status),
+		Times:     map[string]string{},
+	}
+	if err != nil {
+		record.SignatureDetail = err.Error()
+	}
+	for _, key := range timeClaims {
+		if t, ok := claimTime(result.Data.Claims, key); ok {
+			record.Times[key] = t.UTC().Format(time.RFC3339)
+		}
+	}
+
+	if format != formatTable {
+		rows := [][]string{}
+		for _, section := range []struct {
+			name   string
+			values map[string]interface{}
+		}{{"header", record.Header}, {"claims", record.Claims}} {
+			for _, key := range sortedKeys(section.values) {
+				rows = append(rows, []string{section.name, key, claimValue(section.values[key])})
+			}
+		}
+		rows = append(rows, []string{"signature", "status", record.Signature})
+		return emit(format, record, []string{"section", "name", "value"}, rows)
+	}
+
+	printClaims("Header", result.Data.Header)
+	printClaims("Claims", result.Data.Claims)
+
+	switch status {
+	case signatureValid:
+		fmt.Printf("\033[93mSignature:\033[0m \033[92mvalid\033[0m\n")
+	case signatureInvalid:
+		fmt.Printf("\033[93mSignature:\033[0m \033[91minvalid\033[0m (%s)\n", err)
+	default:
+		fmt.Printf("\033[93mSignature:\033[0m unverified (%s)\n", err)
+	}
+
+	return nil
+}
+
+func printClaims(title string, values map[string]interface{}) {
+	t := table.NewWriter()
+	t.SetTitle(title)
+	t.SetStyle(table.StyleColoredDark)
+
+	for _, key := range sortedKeys(values) {
+		value := claimValue(values[key])
+		if ts, ok := claimTime(values, key); ok && containsFold(timeClaims, key) {
+			value = fmt.Sprintf("%s (%s, %s)", value, ts.Local().Format(time.RFC1123), relativeTime(ts))
+		}
+		t.AppendRow(table.Row{key, value})
+	}
+
+	fmt.Println(t.Render())
+}
+
+func claimValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// integralNumbers returns a copy of a decoded JSON value with whole numbers
+// turned into int64, so that YAML writes NumericDate claims such as exp as
+// 1792317963 rather than 1.792317963e+09.
+func integralNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[key] = integralNumbers(value)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			out[i] = integralNumbers(value)
+		}
+		return out
+	}
+	return v
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns what fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	saved := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = saved }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+
+	fnErr := fn()
+	w.Close()
+	return <-done, fnErr
+}
+
+func TestTokenInspectPrintsWholeNumbers(t *testing.T) {
+	keys := useTestEnvironment(t, http.StatusOK)
+	key := generateKey(t)
+	keys.publish(publicJwk("k1", key))
+
+	token := signToken(t, key, "RS256", "k1", map[string]interface{}{
+		"sub":   "user-1",
+		"exp":   1792317963,
+		"iat":   1792314363,
+		"ratio": 0.5,
+		"nums":  []int{1, 2},
+	})
+
+	for _, format := range []string{formatYAML, formatJSON, formatCSV} {
+		t.Run(format, func(t *testing.T) {
+			out, err := captureStdout(t, func() error {
+				return tokenInspect(context.Background(), token, format)
+			})
+			if err != nil {
+				t.Fatalf("tokenInspect: %v", err)
+			}
+			if strings.Contains(out, "e+09") {
+				t.Errorf("output uses exponent form:\n%s", out)
+			}
+			for _, want := range []string{"1792317963", "1792314363", "0.5"} {
+				if !strings.Contains(out, want) {
+					t.Errorf("output lacks %s:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestIntegralNumbers(t *testing.T) {
+	in := map[string]interface{}{
+		"exp":   float64(1792317963),
+		"ratio": 0.25,
+		"big":   1e300,
+		"list":  []interface{}{float64(3), "x"},
+		"obj":   map[string]interface{}{"n": float64(-2)},
+	}
+
+	out := integralNumbers(in).(map[string]interface{})
+	if v, ok := out["exp"].(int64); !ok || v != 1792317963 {
+		t.Errorf("exp = %#v, want int64", out["exp"])
+	}
+	if v, ok := out["ratio"].(float64); !ok || v != 0.25 {
+		t.Errorf("ratio = %#v, want 0.25", out["ratio"])
+	}
+	if _, ok := out["big"].(float64); !ok {
+		t.Errorf("big = %#v, want it left a float", out["big"])
+	}
+	if v, ok := out["list"].([]interface{})[0].(int64); !ok || v != 3 {
+		t.Errorf("list[0] = %#v, want int64", out["list"].([]interface{})[0])
+	}
+	if v, ok := out["obj"].(map[string]interface{})["n"].(int64); !ok || v != -2 {
+		t.Errorf("obj.n = %#v, want int64", out["obj"].(map[string]interface{})["n"])
+	}
+	if _, ok := in["exp"].(float64); !ok {
+		t.Errorf("input was modified")
+	}
+}